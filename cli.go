@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/directory"
+	"github.com/brettcodling/SugarMateReader/internal/export"
+	"github.com/brettcodling/SugarMateReader/internal/importer"
+	"github.com/brettcodling/SugarMateReader/internal/report"
+	"github.com/brettcodling/SugarMateReader/internal/stats"
+	"github.com/brettcodling/SugarMateReader/internal/status"
+	"github.com/brettcodling/SugarMateReader/internal/ui"
+)
+
+// commands are the commands which can be run from the command line instead of the tray app.
+var commands = map[string]func(args []string, out io.Writer) error{
+	"agp":    agpCommand,
+	"export": exportCommand,
+	"import": importCommand,
+	"stats":  statsCommand,
+	"status": statusCommand,
+}
+
+// runCommand runs a command line command and gets the exit code.
+func runCommand(args []string) int {
+	command, ok := commands[args[0]]
+	if !ok {
+		names := slices.Sorted(maps.Keys(commands))
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected one of: %s\n", args[0], strings.Join(names, ", "))
+		return 2
+	}
+	defer database.DB.Close()
+	err := command(args[1:], os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// statsCommand prints the glycemic statistics of a period of stored history.
+func statsCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	name := flags.String("period", "14d", "period to calculate the statistics over: 24h, 7d, 14d, 30d or 90d")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	period, ok := stats.ParsePeriod(*name)
+	if !ok {
+		return fmt.Errorf("unknown period %q", *name)
+	}
+	setting := ui.Settings()
+	calculated, err := stats.Since(time.Now().Add(-period.Duration), setting, database.GetReadings)
+	if err != nil {
+		return err
+	}
+	if calculated.Readings == 0 {
+		fmt.Fprintf(out, "No readings stored in the last %s\n", period.Name)
+		return nil
+	}
+	fmt.Fprintf(out, "Last %s\n", period.Name)
+	for _, row := range calculated.Rows(setting) {
+		fmt.Fprintf(out, "%-26s %s\n", row.Label+":", row.Value)
+	}
+
+	return nil
+}
+
+// agpCommand writes the AGP report of the last 14 days, as a PNG image when the output file ends
+// in .png and as an HTML page otherwise.
+func agpCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("agp", flag.ContinueOnError)
+	output := flags.String("o", "agp.html", "file to write the report to, .html or .png")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	setting := ui.Settings()
+	agp, err := report.Generate(setting, time.Now(), database.GetReadings)
+	if err != nil {
+		return err
+	}
+	render := report.HTML
+	if strings.HasSuffix(strings.ToLower(*output), ".png") {
+		render = report.PNG
+	}
+	body, err := render(agp, setting, directory.ConfigDir+"Roboto-Bold.ttf")
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(*output, body, 0644)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote %s\n", *output)
+
+	return nil
+}
+
+// exportCommand writes the stored readings and treatments between two dates to the output file, or to standard
+// output when no file is given.
+func exportCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	first := flags.String("from", "", "first day to export, YYYY-MM-DD (default 13 days ago)")
+	last := flags.String("to", "", "last day to export, YYYY-MM-DD (default today)")
+	format := flags.String("format", "csv", "format to export: csv, json or ndjson")
+	units := flags.String("units", ui.Settings().Units, "units to export: mgdl or mmol")
+	output := flags.String("o", "", "file to write the readings to")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(export.Formats, *format) {
+		return fmt.Errorf("unknown format %q, expected csv, json or ndjson", *format)
+	}
+	if *units != "mgdl" && *units != "mmol" {
+		return fmt.Errorf("unknown units %q, expected mgdl or mmol", *units)
+	}
+	from, to, err := export.Dates(*first, *last, time.Now())
+	if err != nil {
+		return err
+	}
+	readings, err := database.GetReadings(from, to)
+	if err != nil {
+		return err
+	}
+	treatments, err := database.GetTreatments(from, to)
+	if err != nil {
+		return err
+	}
+	if *output == "" {
+		return export.Write(out, *format, readings, treatments, *units, time.Local)
+	}
+	file, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return export.Write(file, *format, readings, treatments, *units, time.Local)
+}
+
+// importCommand imports the readings of Dexcom Clarity, LibreView or Nightscout exports into the
+// history, reporting the rows which couldn't be imported.
+func importCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "format of the exports: clarity, libreview or nightscout (default detected from the contents)")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("no export files given to import")
+	}
+	invalid := 0
+	for _, name := range flags.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		detected := *format
+		if detected == "" {
+			detected, err = importer.Detect(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		result, err := importer.Parse(detected, data, time.Local)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, rowErr := range result.Errors {
+			fmt.Fprintf(out, "%s: %s\n", name, rowErr)
+		}
+		readings := result.Readings
+		if len(readings) > 0 {
+			stored, err := database.GetReadings(readings[0].Time.Add(-importer.DuplicateWindow), readings[len(readings)-1].Time.Add(importer.DuplicateWindow))
+			if err != nil {
+				return err
+			}
+			readings = importer.Unstored(readings, stored)
+		}
+		imported, err := database.ImportReadings(readings)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%s: imported %d of %d %s readings, %d already stored, %d rows skipped, %d invalid\n",
+			name, imported, len(result.Readings), detected, len(result.Readings)-imported, result.Skipped, len(result.Errors))
+		invalid += len(result.Errors)
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d invalid rows were not imported", invalid)
+	}
+
+	return nil
+}
+
+// statusCommand prints the details of the latest stored reading, on a single line with -short for
+// status bars.
+func statusCommand(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	short := flags.Bool("short", false, "print a single line for status bars")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	err = alerts.Load([]byte(database.Get("ALERT_STATE")))
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	details, err := latestDetails(now)
+	if err != nil {
+		return err
+	}
+	setting := ui.Settings()
+	if *short {
+		fmt.Fprintln(out, status.Short(details, setting, now))
+	} else {
+		fmt.Fprintln(out, status.Format(details, setting, now))
+	}
+
+	return nil
+}
+
+// latestDetails gathers the details of the latest reading stored in the last day, shown by the
+// status command and served from /status.
+func latestDetails(now time.Time) (status.Details, error) {
+	history, err := database.GetReadings(now.Add(-24*time.Hour), now)
+	if err != nil {
+		return status.Details{}, err
+	}
+	if len(history) == 0 {
+		return status.Details{}, nil
+	}
+	// stored readings don't keep their rate
+	reading := history[len(history)-1]
+	reading.Rate = readingRate(reading, history, ui.Settings())
+
+	return readingDetails(reading), nil
+}
@@ -0,0 +1,322 @@
+package alert
+
+import (
+	"encoding/json"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+)
+
+// cadenceSlack allows for readings which don't arrive exactly on the repeat interval.
+const cadenceSlack = 30 * time.Second
+
+// SnoozeDurations are the snooze options offered for every alert.
+var SnoozeDurations = []time.Duration{15 * time.Minute, 30 * time.Minute, 60 * time.Minute}
+
+// Event is emitted by the engine whenever a rule fires.
+type Event struct {
+	Rule      string
+	Title     string
+	Message   string
+	Threshold string
+	Escalates bool
+	Reading   glucose.Reading
+	Time      time.Time
+}
+
+// Input is everything a rule is evaluated against.
+type Input struct {
+	Reading glucose.Reading
+	// History holds the recent stored readings, oldest first.
+	History []glucose.Reading
+	// Treatments holds the recent treatments, for the insulin and carbs on board.
+	Treatments []treatment.Treatment
+	Setting    settings.Setting
+}
+
+// Rule describes a condition which should raise an alert.
+type Rule struct {
+	Name  string
+	Label string
+	Title string
+	// Escalates rules are escalated to a sound and a critical notification when left unacknowledged.
+	Escalates bool
+	// Threshold gets the setting the rule is checked against.
+	Threshold func(setting settings.Setting) string
+	// Repeat gets how often the rule fires again while it is triggered and unacknowledged,
+	// zero means it only fires again once the condition has cleared.
+	Repeat func(setting settings.Setting) time.Duration
+	Check  func(input Input) (message string, triggered bool, err error)
+}
+
+// State is the per-rule state kept between readings.
+type State struct {
+	Active       bool
+	Acknowledged bool
+	Since        time.Time
+	LastFired    time.Time
+	SnoozedUntil time.Time
+}
+
+// Engine evaluates each new reading against its rules exactly once.
+type Engine struct {
+	// Persist is called with the serialised engine state whenever it changes.
+	Persist func(data []byte)
+	// Log is called with the record of an alert whenever it fires, is acknowledged, is snoozed
+	// or clears.
+	Log func(record Record)
+	// Now gets the current time, snoozes are timed by it rather than the reading time as readings
+	// arrive after they are taken. It defaults to time.Now.
+	Now         func() time.Time
+	rules       []Rule
+	records     map[string]*Record
+	subscribers []chan Event
+	state       map[string]*State
+	last        time.Time
+	mutex       sync.Mutex
+}
+
+type persisted struct {
+	Last    time.Time
+	State   map[string]*State
+	Records map[string]*Record
+}
+
+// NewEngine creates an engine for the given rules.
+func NewEngine(rules ...Rule) *Engine {
+	engine := &Engine{
+		rules:   rules,
+		records: map[string]*Record{},
+		state:   map[string]*State{},
+	}
+	for _, rule := range rules {
+		engine.state[rule.Name] = &State{}
+	}
+
+	return engine
+}
+
+// Subscribe gets a channel which receives every event emitted by the engine.
+func (e *Engine) Subscribe() <-chan Event {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	events := make(chan Event, 10)
+	e.subscribers = append(e.subscribers, events)
+
+	return events
+}
+
+// Rules gets the rules evaluated by the engine.
+func (e *Engine) Rules() []Rule {
+	return e.rules
+}
+
+// Load restores engine state previously passed to Persist.
+func (e *Engine) Load(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	var saved persisted
+	err := json.Unmarshal(data, &saved)
+	if err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.last = saved.Last
+	for name, state := range saved.State {
+		if _, ok := e.state[name]; ok && state != nil {
+			e.state[name] = state
+		}
+	}
+	for name, record := range saved.Records {
+		if _, ok := e.state[name]; ok && record != nil {
+			e.records[name] = record
+		}
+	}
+
+	return nil
+}
+
+// Evaluate checks a reading against every rule, readings which have already been seen are ignored.
+// The events are sent once the mutex is released, so a slow subscriber doesn't stop alerts being
+// acknowledged or snoozed.
+func (e *Engine) Evaluate(input Input) {
+	events, subscribers := e.evaluate(input)
+	for _, event := range events {
+		for _, subscriber := range subscribers {
+			subscriber <- event
+		}
+	}
+}
+
+// evaluate updates the state of every rule for the reading, returning the events to send and the
+// subscribers to send them to.
+func (e *Engine) evaluate(input Input) ([]Event, []chan Event) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	reading := input.Reading
+	if reading.Missing() || !reading.Time.After(e.last) {
+		return nil, nil
+	}
+	e.last = reading.Time
+
+	var events []Event
+	for _, rule := range e.rules {
+		state := e.state[rule.Name]
+		message, triggered, err := rule.Check(input)
+		if err != nil {
+			log.Println("error:")
+			log.Println(err)
+			continue
+		}
+		if !triggered {
+			if record := e.current(rule.Name); state.Active && record != nil {
+				record.Cleared = reading.Time
+				e.log(record)
+			}
+			state.Active = false
+			state.Acknowledged = false
+			continue
+		}
+		if !e.shouldFire(rule, state, reading.Time, input.Setting) {
+			continue
+		}
+		state.LastFired = reading.Time
+		event := Event{
+			Rule:      rule.Name,
+			Title:     rule.Title,
+			Message:   message,
+			Escalates: rule.Escalates,
+			Reading:   reading,
+			Time:      reading.Time,
+		}
+		if rule.Threshold != nil {
+			event.Threshold = rule.Threshold(input.Setting)
+		}
+		events = append(events, event)
+		e.records[rule.Name] = &Record{
+			Rule:    rule.Name,
+			Message: message,
+			MgDl:    reading.MgDl,
+			Time:    reading.Time,
+			Since:   state.Since,
+		}
+		e.log(e.records[rule.Name])
+	}
+	e.persist()
+
+	return events, slices.Clone(e.subscribers)
+}
+
+// shouldFire updates the state of a triggered rule and reports whether it should fire.
+func (e *Engine) shouldFire(rule Rule, state *State, now time.Time, setting settings.Setting) bool {
+	if !state.Active {
+		state.Active = true
+		state.Acknowledged = false
+		state.Since = now
+		state.LastFired = time.Time{}
+	}
+	if state.Acknowledged || e.now().Before(state.SnoozedUntil) {
+		return false
+	}
+	if !state.SnoozedUntil.IsZero() {
+		state.SnoozedUntil = time.Time{}
+		return true
+	}
+	if state.LastFired.IsZero() {
+		return true
+	}
+	var repeat time.Duration
+	if rule.Repeat != nil {
+		repeat = rule.Repeat(setting)
+	}
+
+	return repeat > 0 && now.Add(cadenceSlack).Sub(state.LastFired) >= repeat
+}
+
+// Acknowledge stops a rule from firing again until its condition has cleared.
+func (e *Engine) Acknowledge(name string) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if state, ok := e.state[name]; ok && state.Active {
+		state.Acknowledged = true
+		if record := e.current(name); record != nil {
+			record.Acknowledged = e.now()
+			e.log(record)
+		}
+		e.persist()
+	}
+}
+
+// Snooze stops a rule from firing for the given duration.
+func (e *Engine) Snooze(name string, duration time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if state, ok := e.state[name]; ok {
+		state.SnoozedUntil = e.now().Add(duration)
+		if record := e.current(name); state.Active && record != nil {
+			record.SnoozedUntil = state.SnoozedUntil
+			e.log(record)
+		}
+		e.persist()
+	}
+}
+
+// State gets a copy of the current state of a rule.
+func (e *Engine) State(name string) State {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if state, ok := e.state[name]; ok {
+		return *state
+	}
+
+	return State{}
+}
+
+// now gets the current time from Now.
+func (e *Engine) now() time.Time {
+	if e.Now != nil {
+		return e.Now()
+	}
+
+	return time.Now()
+}
+
+// current gets the record of the alert last fired by a rule when it belongs to the condition the
+// rule is currently in, the mutex must be held.
+func (e *Engine) current(name string) *Record {
+	record, ok := e.records[name]
+	if !ok || !record.Cleared.IsZero() || !record.Since.Equal(e.state[name].Since) {
+		return nil
+	}
+
+	return record
+}
+
+// log passes a copy of the record to Log, the mutex must be held.
+func (e *Engine) log(record *Record) {
+	if e.Log != nil {
+		e.Log(*record)
+	}
+}
+
+// persist passes the current state to Persist, the mutex must be held.
+func (e *Engine) persist() {
+	if e.Persist == nil {
+		return
+	}
+	data, err := json.Marshal(persisted{Last: e.last, State: e.state, Records: e.records})
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		return
+	}
+	e.Persist(data)
+}
@@ -0,0 +1,161 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+func testSetting() settings.Setting {
+	return settings.Setting{
+		Alerts: settings.Alert{
+			LowEnabled:        "true",
+			Low:               "72",
+			LowRepeat:         "5",
+			HighEnabled:       "true",
+			High:              "216",
+			HighRepeat:        "0",
+			FastChangeEnabled: "true",
+			FastChange:        "9",
+			FastChangeRepeat:  "5",
+		},
+		Units: "mmol",
+	}
+}
+
+func drain(events <-chan Event) []Event {
+	var drained []Event
+	for {
+		select {
+		case event := <-events:
+			drained = append(drained, event)
+		default:
+			return drained
+		}
+	}
+}
+
+func TestEvaluateOncePerReading(t *testing.T) {
+	engine := NewEngine(Low, High, FastChange)
+	events := engine.Subscribe()
+	reading := glucose.Reading{Time: time.Now(), MgDl: 60, Trend: "FLAT"}
+	engine.Evaluate(Input{Reading: reading, Setting: testSetting()})
+	engine.Evaluate(Input{Reading: reading, Setting: testSetting()})
+	if drained := drain(events); len(drained) != 1 || drained[0].Rule != "low" {
+		t.Fatalf("expected a single low event, got %v", drained)
+	}
+}
+
+func TestEvaluateRepeat(t *testing.T) {
+	engine := NewEngine(Low, High)
+	events := engine.Subscribe()
+	start := time.Now()
+	for i, mgdl := range []int{60, 60, 250, 250, 100, 250} {
+		engine.Evaluate(Input{Reading: glucose.Reading{Time: start.Add(time.Duration(i) * 5 * time.Minute), MgDl: mgdl}, Setting: testSetting()})
+	}
+	var rules []string
+	for _, event := range drain(events) {
+		rules = append(rules, event.Rule)
+	}
+	want := []string{"low", "low", "high", "high"}
+	if len(rules) != len(want) {
+		t.Fatalf("expected %v, got %v", want, rules)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, rules)
+		}
+	}
+}
+
+func TestAcknowledgeAndSnooze(t *testing.T) {
+	engine := NewEngine(Low)
+	events := engine.Subscribe()
+	start := time.Now()
+	// readings arrive 5 minutes after they're taken
+	clock := start.Add(5 * time.Minute)
+	engine.Now = func() time.Time {
+		return clock
+	}
+	low := func(minutes int) {
+		clock = start.Add(time.Duration(minutes+5) * time.Minute)
+		engine.Evaluate(Input{Reading: glucose.Reading{Time: start.Add(time.Duration(minutes) * time.Minute), MgDl: 60}, Setting: testSetting()})
+	}
+	low(0)
+	engine.Acknowledge("low")
+	low(5)
+	if drained := drain(events); len(drained) != 1 {
+		t.Fatalf("expected acknowledged alert to fire once, got %d", len(drained))
+	}
+
+	engine.Evaluate(Input{Reading: glucose.Reading{Time: start.Add(10 * time.Minute), MgDl: 100}, Setting: testSetting()})
+	engine.Snooze("low", 15*time.Minute)
+	low(15)
+	if drained := drain(events); len(drained) != 0 {
+		t.Fatalf("expected snoozed alert not to fire, got %d", len(drained))
+	}
+	// taken before the snooze ends but arriving after it
+	low(23)
+	if drained := drain(events); len(drained) != 1 {
+		t.Fatalf("expected alert to fire after the snooze, got %d", len(drained))
+	}
+}
+
+func TestLoadPersistedState(t *testing.T) {
+	var saved []byte
+	engine := NewEngine(Low)
+	engine.Persist = func(data []byte) {
+		saved = data
+	}
+	reading := glucose.Reading{Time: time.Now(), MgDl: 60}
+	engine.Evaluate(Input{Reading: reading, Setting: testSetting()})
+	engine.Acknowledge("low")
+
+	restored := NewEngine(Low)
+	events := restored.Subscribe()
+	err := restored.Load(saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restored.State("low").Acknowledged {
+		t.Error("expected acknowledged state to be restored")
+	}
+	restored.Evaluate(Input{Reading: reading, Setting: testSetting()})
+	if drained := drain(events); len(drained) != 0 {
+		t.Errorf("expected the restored engine to skip a seen reading, got %d events", len(drained))
+	}
+}
+
+func TestSnoozeWhileSubscriberBlocked(t *testing.T) {
+	engine := NewEngine(Low)
+	events := engine.Subscribe()
+	start := time.Now()
+	// more events than the subscriber buffers, sent while nothing is receiving
+	done := make(chan bool)
+	go func() {
+		for i := range 12 {
+			engine.Evaluate(Input{Reading: glucose.Reading{Time: start.Add(time.Duration(i) * 10 * time.Minute), MgDl: 60}, Setting: testSetting()})
+		}
+		done <- true
+	}()
+	time.Sleep(50 * time.Millisecond)
+	snoozed := make(chan bool)
+	go func() {
+		engine.Snooze("low", 15*time.Minute)
+		snoozed <- true
+	}()
+	select {
+	case <-snoozed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("snoozing waited for the subscriber")
+	}
+	for {
+		select {
+		case <-events:
+		case <-done:
+			return
+		}
+	}
+}
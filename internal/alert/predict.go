@@ -0,0 +1,145 @@
+package alert
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+)
+
+const (
+	// predictionWindow is how far back readings are used to fit the trend.
+	predictionWindow = 30 * time.Minute
+	// predictionHalfLife weights recent readings more heavily than older ones.
+	predictionHalfLife        = 10 * time.Minute
+	minimumPredictionReadings = 3
+)
+
+// PredictiveLow fires when the trend, or the insulin and carbs on board, project the reading to
+// reach the low alert level within the configured number of minutes.
+var PredictiveLow = Rule{
+	Name:  "predictive_low",
+	Label: "Predicted low",
+	Title: "ALERT!",
+	Threshold: func(setting settings.Setting) string {
+		return setting.ToUnits(setting.Alerts.Low)
+	},
+	Repeat: func(setting settings.Setting) time.Duration {
+		return settings.Minutes(setting.Alerts.LowRepeat)
+	},
+	Check: func(input Input) (string, bool, error) {
+		if input.Setting.Alerts.PredictiveLowEnabled != "true" || input.Setting.Alerts.Low == "" {
+			return "", false, nil
+		}
+		low, err := settings.Level(input.Setting.Alerts.Low)
+		if err != nil {
+			return "", false, err
+		}
+		horizon, err := strconv.ParseFloat(input.Setting.Alerts.PredictiveLow, 64)
+		if err != nil {
+			return "", false, err
+		}
+		// the low alert covers readings which are already low
+		if input.Setting.Round(float64(input.Reading.MgDl)) <= input.Setting.Round(low) {
+			return "", false, nil
+		}
+		minutesToLow := math.Inf(1)
+		intercept, slope, ok := projection(input.Reading, input.History)
+		if ok && slope < 0 && (low-intercept)/slope > 0 {
+			minutesToLow = (low - intercept) / slope
+		}
+		// active insulin can bring on a low before the trend shows it, invalid insulin settings
+		// leave the trend to predict the low
+		minutes, ok, err := onBoardMinutesToLow(input, low, horizon)
+		if err != nil {
+			log.Println("error:")
+			log.Println(err)
+		}
+		if ok {
+			minutesToLow = math.Min(minutesToLow, minutes)
+		}
+		if minutesToLow > horizon {
+			return "", false, nil
+		}
+		message := fmt.Sprintf("PREDICTED LOW IN ~%.0f MIN", math.Ceil(minutesToLow))
+		onBoard, err := treatment.Calculate(input.Treatments, input.Setting.Insulin, input.Reading.Time, input.Reading.Time)
+		if err == nil && onBoard.Insulin >= 0.05 {
+			message += fmt.Sprintf(" (%.1f U ON BOARD)", onBoard.Insulin)
+		}
+
+		return message, true, nil
+	},
+}
+
+// onBoardMinutesToLow projects the reading forward by the effect of the insulin and carbs on board
+// to find the minutes until it reaches low within the horizon, ok is false when it doesn't or the
+// insulin sensitivity isn't set.
+func onBoardMinutesToLow(input Input, low, horizon float64) (float64, bool, error) {
+	if len(input.Treatments) == 0 || input.Setting.Insulin.Sensitivity == "" {
+		return 0, false, nil
+	}
+	now := input.Reading.Time
+	for minute := 1.0; minute <= horizon; minute++ {
+		effect, ok, err := treatment.Effect(input.Treatments, input.Setting.Insulin, now, now.Add(time.Duration(minute)*time.Minute))
+		if err != nil || !ok {
+			return 0, false, err
+		}
+		if float64(input.Reading.MgDl)+effect <= low {
+			return minute, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// projection fits a weighted linear regression to the recent readings, returning the fitted
+// mg/dl at the time of the reading and the change in mg/dl per minute.
+func projection(reading glucose.Reading, history []glucose.Reading) (float64, float64, bool) {
+	var points [][2]float64
+	seenCurrent := false
+	for _, previous := range slices.Concat(history, []glucose.Reading{reading}) {
+		age := reading.Time.Sub(previous.Time)
+		if previous.Missing() || age < 0 || age > predictionWindow {
+			continue
+		}
+		if age == 0 {
+			if seenCurrent {
+				continue
+			}
+			seenCurrent = true
+		}
+		points = append(points, [2]float64{-age.Minutes(), float64(previous.MgDl)})
+	}
+	if len(points) < minimumPredictionReadings {
+		return 0, 0, false
+	}
+
+	var weightSum, xMean, yMean float64
+	weights := make([]float64, len(points))
+	for i, point := range points {
+		weights[i] = math.Exp2(point[0] / predictionHalfLife.Minutes())
+		weightSum += weights[i]
+		xMean += weights[i] * point[0]
+		yMean += weights[i] * point[1]
+	}
+	xMean /= weightSum
+	yMean /= weightSum
+
+	var covariance, variance float64
+	for i, point := range points {
+		covariance += weights[i] * (point[0] - xMean) * (point[1] - yMean)
+		variance += weights[i] * (point[0] - xMean) * (point[0] - xMean)
+	}
+	if variance == 0 {
+		return 0, 0, false
+	}
+	slope := covariance / variance
+
+	return yMean - slope*xMean, slope, true
+}
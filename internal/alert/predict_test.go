@@ -0,0 +1,105 @@
+package alert
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+)
+
+func TestPredictiveLow(t *testing.T) {
+	setting := testSetting()
+	setting.Alerts.PredictiveLowEnabled = "true"
+	setting.Alerts.PredictiveLow = "20"
+	now := time.Now()
+	history := func(mgdl ...int) []glucose.Reading {
+		readings := make([]glucose.Reading, len(mgdl))
+		for i, value := range mgdl {
+			readings[i] = glucose.Reading{Time: now.Add(time.Duration(i-len(mgdl)+1) * 5 * time.Minute), MgDl: value}
+		}
+		return readings
+	}
+
+	tests := []struct {
+		name      string
+		history   []glucose.Reading
+		triggered bool
+	}{
+		{"falling towards low", history(120, 112, 104, 96), true},
+		{"falling slowly", history(120, 119, 118, 117), false},
+		{"rising", history(80, 85, 90, 95), false},
+		{"already low", history(80, 75, 70, 65), false},
+		{"not enough readings", history(104, 96), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reading := tt.history[len(tt.history)-1]
+			_, triggered, err := PredictiveLow.Check(Input{Reading: reading, History: tt.history, Setting: setting})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if triggered != tt.triggered {
+				t.Errorf("expected triggered to be %v", tt.triggered)
+			}
+		})
+	}
+}
+
+func TestPredictiveLowOnBoard(t *testing.T) {
+	setting := testSetting()
+	setting.Alerts.PredictiveLowEnabled = "true"
+	setting.Alerts.PredictiveLow = "20"
+	setting.Insulin = settings.Insulin{Curve: "rapid", DIA: "5", Sensitivity: "54", CarbRatio: "10", CarbRate: "30"}
+	now := time.Now()
+	var history []glucose.Reading
+	for i, value := range []int{120, 119, 118, 117} {
+		history = append(history, glucose.Reading{Time: now.Add(time.Duration(i-3) * 5 * time.Minute), MgDl: value})
+	}
+	input := Input{
+		Reading:    history[3],
+		History:    history,
+		Treatments: []treatment.Treatment{{Time: now.Add(-time.Hour), Type: treatment.Insulin, Amount: 10}},
+		Setting:    setting,
+	}
+
+	message, triggered, err := PredictiveLow.Check(input)
+	if err != nil || !triggered || !strings.Contains(message, "U ON BOARD") {
+		t.Errorf("active insulin should predict a low the trend doesn't, got %q, %v, %v", message, triggered, err)
+	}
+	input.Treatments = append(input.Treatments, treatment.Treatment{Time: now.Add(-10 * time.Minute), Type: treatment.Carbs, Amount: 60})
+	_, triggered, _ = PredictiveLow.Check(input)
+	if triggered {
+		t.Error("carbs on board should cover the insulin")
+	}
+	input.Setting.Insulin.Sensitivity = ""
+	_, triggered, _ = PredictiveLow.Check(input)
+	if triggered {
+		t.Error("insulin on board is only used when the sensitivity is set")
+	}
+}
+
+func TestPredictiveLowInvalidInsulin(t *testing.T) {
+	setting := testSetting()
+	setting.Alerts.PredictiveLowEnabled = "true"
+	setting.Alerts.PredictiveLow = "20"
+	setting.Insulin = settings.Insulin{Curve: "custom", DIA: "", Peak: "75", Sensitivity: "54", CarbRate: "30"}
+	now := time.Now()
+	var history []glucose.Reading
+	for i, value := range []int{120, 112, 104, 96} {
+		history = append(history, glucose.Reading{Time: now.Add(time.Duration(i-3) * 5 * time.Minute), MgDl: value})
+	}
+	input := Input{
+		Reading:    history[3],
+		History:    history,
+		Treatments: []treatment.Treatment{{Time: now.Add(-time.Hour), Type: treatment.Insulin, Amount: 2}},
+		Setting:    setting,
+	}
+
+	_, triggered, err := PredictiveLow.Check(input)
+	if err != nil || !triggered {
+		t.Errorf("the trend should still predict a low when the insulin settings are invalid, got %v, %v", triggered, err)
+	}
+}
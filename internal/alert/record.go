@@ -0,0 +1,61 @@
+package alert
+
+import (
+	"slices"
+	"time"
+)
+
+// Record is the history of a fired alert, it is updated as the alert is acknowledged, snoozed and
+// cleared.
+type Record struct {
+	Rule    string
+	Message string
+	MgDl    int
+	// Time is when the alert fired.
+	Time time.Time
+	// Since is when the condition which fired the alert started.
+	Since        time.Time
+	Acknowledged time.Time
+	SnoozedUntil time.Time
+	// Cleared is when the condition which fired the alert cleared.
+	Cleared time.Time
+}
+
+// DayCount is the number of alerts fired by each rule on a day.
+type DayCount struct {
+	Day   time.Time
+	Rules map[string]int
+	Total int
+}
+
+// Duration gets how long the condition which fired the alert lasted, or has lasted so far.
+func (r Record) Duration(now time.Time) time.Duration {
+	if !r.Cleared.IsZero() {
+		return r.Cleared.Sub(r.Since)
+	}
+
+	return now.Sub(r.Since)
+}
+
+// CountByDay counts the alerts fired each day in the location, newest day first.
+func CountByDay(records []Record, location *time.Location) []DayCount {
+	var counts []DayCount
+	index := map[time.Time]int{}
+	for _, record := range records {
+		fired := record.Time.In(location)
+		day := time.Date(fired.Year(), fired.Month(), fired.Day(), 0, 0, 0, 0, location)
+		i, ok := index[day]
+		if !ok {
+			i = len(counts)
+			index[day] = i
+			counts = append(counts, DayCount{Day: day, Rules: map[string]int{}})
+		}
+		counts[i].Rules[record.Rule]++
+		counts[i].Total++
+	}
+	slices.SortFunc(counts, func(a, b DayCount) int {
+		return b.Day.Compare(a.Day)
+	})
+
+	return counts
+}
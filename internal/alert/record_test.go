@@ -0,0 +1,65 @@
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+)
+
+func TestLogRecords(t *testing.T) {
+	engine := NewEngine(Low)
+	var logged []Record
+	engine.Log = func(record Record) {
+		logged = append(logged, record)
+	}
+	start := time.Now()
+	evaluate := func(i int, mgdl int) {
+		engine.Evaluate(Input{Reading: glucose.Reading{Time: start.Add(time.Duration(i) * 5 * time.Minute), MgDl: mgdl}, Setting: testSetting()})
+	}
+
+	evaluate(0, 60)
+	engine.Acknowledge("low")
+	evaluate(1, 60)
+	evaluate(2, 100)
+
+	if len(logged) != 3 {
+		t.Fatalf("expected fired, acknowledged and cleared records, got %+v", logged)
+	}
+	fired, acknowledged, cleared := logged[0], logged[1], logged[2]
+	if fired.Rule != "low" || fired.MgDl != 60 || !fired.Time.Equal(start) || !fired.Acknowledged.IsZero() {
+		t.Errorf("fired record = %+v", fired)
+	}
+	if !acknowledged.Time.Equal(start) || acknowledged.Acknowledged.IsZero() {
+		t.Errorf("acknowledged record = %+v", acknowledged)
+	}
+	if !cleared.Cleared.Equal(start.Add(10*time.Minute)) || cleared.Duration(time.Now()) != 10*time.Minute {
+		t.Errorf("cleared record = %+v", cleared)
+	}
+
+	// a snooze outside of an alert has nothing to record
+	engine.Snooze("low", time.Hour)
+	if len(logged) != 3 {
+		t.Errorf("snooze without an alert was recorded: %+v", logged[3:])
+	}
+}
+
+func TestCountByDay(t *testing.T) {
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	records := []Record{
+		{Rule: "low", Time: day.Add(time.Hour)},
+		{Rule: "low", Time: day.Add(2 * time.Hour)},
+		{Rule: "high", Time: day.Add(3 * time.Hour)},
+		{Rule: "high", Time: day.Add(25 * time.Hour)},
+	}
+	counts := CountByDay(records, time.UTC)
+	if len(counts) != 2 {
+		t.Fatalf("expected 2 days, got %+v", counts)
+	}
+	if !counts[0].Day.Equal(day.AddDate(0, 0, 1)) || counts[0].Total != 1 || counts[0].Rules["high"] != 1 {
+		t.Errorf("newest day = %+v", counts[0])
+	}
+	if !counts[1].Day.Equal(day) || counts[1].Total != 3 || counts[1].Rules["low"] != 2 || counts[1].Rules["high"] != 1 {
+		t.Errorf("oldest day = %+v", counts[1])
+	}
+}
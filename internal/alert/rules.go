@@ -0,0 +1,91 @@
+package alert
+
+import (
+	"math"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+// Rules are every rule in the order they are shown.
+var Rules = []Rule{Low, PredictiveLow, High, FastChange}
+
+// Low fires when the reading is at or below the low alert level.
+var Low = Rule{
+	Name:      "low",
+	Label:     "Low",
+	Title:     "ALERT!",
+	Escalates: true,
+	Threshold: func(setting settings.Setting) string {
+		return setting.ToUnits(setting.Alerts.Low)
+	},
+	Repeat: func(setting settings.Setting) time.Duration {
+		return settings.Minutes(setting.Alerts.LowRepeat)
+	},
+	Check: func(input Input) (string, bool, error) {
+		if input.Setting.Alerts.LowEnabled != "true" {
+			return "", false, nil
+		}
+		lowAlertLevel, err := settings.Level(input.Setting.Alerts.Low)
+		if err != nil {
+			return "", false, err
+		}
+		value := input.Setting.Round(float64(input.Reading.MgDl))
+
+		return "LOW GLUCOSE", lowAlertLevel > 0 && value <= input.Setting.Round(lowAlertLevel), nil
+	},
+}
+
+// High fires when the reading reaches the high alert level.
+var High = Rule{
+	Name:  "high",
+	Label: "High",
+	Title: "ALERT!",
+	Threshold: func(setting settings.Setting) string {
+		return setting.ToUnits(setting.Alerts.High)
+	},
+	Repeat: func(setting settings.Setting) time.Duration {
+		return settings.Minutes(setting.Alerts.HighRepeat)
+	},
+	Check: func(input Input) (string, bool, error) {
+		if input.Setting.Alerts.HighEnabled != "true" {
+			return "", false, nil
+		}
+		highAlertLevel, err := settings.Level(input.Setting.Alerts.High)
+		if err != nil {
+			return "", false, err
+		}
+		value := input.Setting.Round(float64(input.Reading.MgDl))
+
+		return "HIGH GLUCOSE", highAlertLevel > 0 && value >= input.Setting.Round(highAlertLevel), nil
+	},
+}
+
+// FastChange fires when the reading changes by at least the fast change level every 5 minutes.
+var FastChange = Rule{
+	Name:  "fast_change",
+	Label: "Fast change",
+	Title: "ALERT!",
+	Threshold: func(setting settings.Setting) string {
+		return setting.ToUnits(setting.Alerts.FastChange)
+	},
+	Repeat: func(setting settings.Setting) time.Duration {
+		return settings.Minutes(setting.Alerts.FastChangeRepeat)
+	},
+	Check: func(input Input) (string, bool, error) {
+		if input.Setting.Alerts.FastChangeEnabled != "true" {
+			return "", false, nil
+		}
+		fastChangeLevel, err := settings.Level(input.Setting.Alerts.FastChange)
+		if err != nil {
+			return "", false, err
+		}
+		change := input.Reading.Rate * 5
+		fast := input.Setting.Round(math.Abs(change)) >= input.Setting.Round(fastChangeLevel)
+		if change > 0 {
+			return "RISING FAST", fast, nil
+		}
+
+		return "FALLING FAST", fast, nil
+	},
+}
@@ -0,0 +1,115 @@
+package alert
+
+import (
+	"testing"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+func TestThresholdBoundaries(t *testing.T) {
+	mmol := func(low, high string) settings.Setting {
+		setting := testSetting()
+		setting.Format = "%.1f"
+		setting.Alerts.Low = settings.FromUnits(low, "mmol")
+		setting.Alerts.High = settings.FromUnits(high, "mmol")
+
+		return setting
+	}
+	mgdl := testSetting()
+	mgdl.Units = "mgdl"
+	mgdl.Format = "%.0f"
+	mgdl.Alerts.Low = "70"
+	mgdl.Alerts.High = "180"
+
+	tests := []struct {
+		name      string
+		rule      Rule
+		setting   settings.Setting
+		mgdl      int
+		triggered bool
+	}{
+		// 72 mg/dl is 3.996 mmol/l, shown as 4.0
+		{"3.9 low at 4.0", Low, mmol("3.9", "10.0"), 72, false},
+		// 71 mg/dl is 3.94 mmol/l, shown as 3.9
+		{"3.9 low at 3.9", Low, mmol("3.9", "10.0"), 71, true},
+		{"4.0 low at 4.0", Low, mmol("4.0", "10.0"), 72, true},
+		// 73 mg/dl is 4.05 mmol/l, shown as 4.1
+		{"4.0 low at 4.1", Low, mmol("4.0", "10.0"), 73, false},
+		// 180 mg/dl is 9.99 mmol/l, shown as 10.0
+		{"10.0 high at 10.0", High, mmol("3.9", "10.0"), 180, true},
+		{"10.0 high at 9.9", High, mmol("3.9", "10.0"), 179, false},
+		{"70 low at 70", Low, mgdl, 70, true},
+		{"70 low at 71", Low, mgdl, 71, false},
+		{"180 high at 180", High, mgdl, 180, true},
+		{"180 high at 179", High, mgdl, 179, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, triggered, err := tt.rule.Check(Input{Reading: glucose.Reading{MgDl: tt.mgdl}, Setting: tt.setting})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if triggered != tt.triggered {
+				t.Errorf("expected triggered to be %v", tt.triggered)
+			}
+		})
+	}
+}
+
+func TestFastChangeBoundary(t *testing.T) {
+	setting := testSetting()
+	setting.Format = "%.1f"
+	setting.Alerts.FastChange = settings.FromUnits("0.5", "mmol")
+	// 8.5 mg/dl every 5 minutes is 0.47 mmol/l, shown as 0.5
+	_, triggered, _ := FastChange.Check(Input{Reading: glucose.Reading{MgDl: 100, Rate: -1.7}, Setting: setting})
+	if !triggered {
+		t.Error("a change shown as 0.5 should reach a 0.5 fast change level")
+	}
+	// 8 mg/dl every 5 minutes is 0.44 mmol/l, shown as 0.4
+	_, triggered, _ = FastChange.Check(Input{Reading: glucose.Reading{MgDl: 100, Rate: -1.6}, Setting: setting})
+	if triggered {
+		t.Error("a change shown as 0.4 should not reach a 0.5 fast change level")
+	}
+}
+
+func TestThresholdBoundariesWithDecimals(t *testing.T) {
+	tests := []struct {
+		name      string
+		decimals  string
+		rule      Rule
+		reading   glucose.Reading
+		triggered bool
+	}{
+		// 80 mg/dl is 4.44 mmol/l, shown as 4 with no decimals
+		{"3.9 low at 4.4 with 0 decimals", "0", Low, glucose.Reading{MgDl: 80}, false},
+		{"3.9 low at 3.9 with 0 decimals", "0", Low, glucose.Reading{MgDl: 71}, true},
+		// 71 mg/dl is 3.94 mmol/l
+		{"3.9 low at 3.94 with 2 decimals", "2", Low, glucose.Reading{MgDl: 71}, true},
+		{"3.9 low at 4.00 with 2 decimals", "2", Low, glucose.Reading{MgDl: 72}, false},
+		{"10.0 high at 9.99 with 2 decimals", "2", High, glucose.Reading{MgDl: 180}, true},
+		{"10.0 high at 9.9 with 0 decimals", "0", High, glucose.Reading{MgDl: 179}, false},
+		// the default 9 mg/dl fast change level is 0.5 mmol/l
+		{"steady with 0 decimals", "0", FastChange, glucose.Reading{MgDl: 100, Rate: 0}, false},
+		{"fast with 0 decimals", "0", FastChange, glucose.Reading{MgDl: 100, Rate: -1.8}, true},
+		{"steady with 2 decimals", "2", FastChange, glucose.Reading{MgDl: 100, Rate: -0.2}, false},
+		{"fast with 2 decimals", "2", FastChange, glucose.Reading{MgDl: 100, Rate: 1.8}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setting := testSetting()
+			setting.Display.Decimals = tt.decimals
+			setting.Format = settings.ValueFormat("mmol", tt.decimals)
+			setting.Alerts.Low = settings.FromUnits("3.9", "mmol")
+			setting.Alerts.High = settings.FromUnits("10.0", "mmol")
+			setting.Alerts.FastChange = "9"
+			_, triggered, err := tt.rule.Check(Input{Reading: tt.reading, Setting: setting})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if triggered != tt.triggered {
+				t.Errorf("expected triggered to be %v", tt.triggered)
+			}
+		})
+	}
+}
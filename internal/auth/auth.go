@@ -7,10 +7,12 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	_ "embed"
 
 	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/metrics"
 	"github.com/brettcodling/SugarMateReader/internal/notify"
 	keyring "github.com/zalando/go-keyring"
 )
@@ -52,18 +54,22 @@ func GetAuth() {
 	}
 	jsonBody := []byte(`{"email": "` + Email + `", "password": "` + Password + `"}`)
 	bodyReader := bytes.NewReader(jsonBody)
+	start := time.Now()
 	resp, err := http.Post("https://api.sugarmate.io/oauth/web", "application/json", bodyReader)
+	metrics.ObserveRequest("oauth/web", time.Since(start), err != nil || resp.StatusCode != http.StatusOK)
 	if err != nil {
+		metrics.Auth("login", false)
 		notify.Warning("ERROR!", err.Error())
 		log.Println("error:")
 		log.Println(err)
 
 		return
 	}
-	parseTokenBody(resp)
+	metrics.Auth("login", parseTokenBody(resp))
 }
 
-func parseTokenBody(resp *http.Response) {
+// parseTokenBody parses the token from the response and reports whether it succeeded.
+func parseTokenBody(resp *http.Response) bool {
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -71,29 +77,33 @@ func parseTokenBody(resp *http.Response) {
 		log.Println("error:")
 		log.Println(err)
 
-		return
+		return false
 	}
 	if resp.StatusCode != http.StatusOK {
 		notify.Warning("ERROR!", "Failed Auth")
 		log.Println("error:")
 		log.Println("Failed Auth.")
 
-		return
+		return false
 	}
-	json.Unmarshal(body, &Token)
+
+	return json.Unmarshal(body, &Token) == nil
 }
 
 // refreshToken gets the access token from the SugarMate oauth endpoint using a refresh token.
 func refreshToken() {
 	jsonBody := []byte(`{"access_token": "` + Token.AccessToken + `", "refresh_token": "` + Token.RefreshToken + `"}`)
 	bodyReader := bytes.NewReader(jsonBody)
+	start := time.Now()
 	resp, err := http.Post("https://api.sugarmate.io/oauth/web/refresh", "application/json", bodyReader)
+	metrics.ObserveRequest("oauth/web/refresh", time.Since(start), err != nil || resp.StatusCode != http.StatusOK)
 	if err != nil {
+		metrics.Auth("refresh", false)
 		notify.Warning("ERROR!", err.Error())
 		log.Println("error:")
 		log.Println(err)
 
 		return
 	}
-	parseTokenBody(resp)
+	metrics.Auth("refresh", parseTokenBody(resp))
 }
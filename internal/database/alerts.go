@@ -0,0 +1,85 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	bolt "go.etcd.io/bbolt"
+)
+
+var alertsBucket = []byte("Alerts")
+
+// alertKey gets the key for an alert record, keys sort in the order the alerts fired.
+func alertKey(record alert.Record) []byte {
+	return append(readingKey(record.Time), record.Rule...)
+}
+
+// SaveAlert stores the alert record, replacing the record of the same alert. When the record has
+// cleared the earlier records fired by the same condition are cleared too.
+func SaveAlert(record alert.Record) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(alertsBucket)
+		if err != nil {
+			return err
+		}
+		if !record.Cleared.IsZero() {
+			// the cursor is invalidated by changing the bucket so the earlier records are
+			// collected before they are updated
+			earlier := map[string]alert.Record{}
+			c := b.Cursor()
+			for k, v := c.Seek(readingKey(record.Since)); k != nil; k, v = c.Next() {
+				var fired alert.Record
+				err := json.Unmarshal(v, &fired)
+				if err != nil {
+					return err
+				}
+				if fired.Rule == record.Rule && fired.Since.Equal(record.Since) && fired.Cleared.IsZero() {
+					fired.Cleared = record.Cleared
+					earlier[string(k)] = fired
+				}
+			}
+			for k, fired := range earlier {
+				value, err := json.Marshal(fired)
+				if err != nil {
+					return err
+				}
+				err = b.Put([]byte(k), value)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		return b.Put(alertKey(record), value)
+	})
+}
+
+// GetAlerts gets the alert records which fired between from and to, oldest first.
+func GetAlerts(from, to time.Time) ([]alert.Record, error) {
+	records := []alert.Record{}
+	err := DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(alertsBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		max := readingKey(to.Add(time.Nanosecond))
+		for k, v := c.Seek(readingKey(from)); k != nil && bytes.Compare(k, max) < 0; k, v = c.Next() {
+			var record alert.Record
+			err := json.Unmarshal(v, &record)
+			if err != nil {
+				return err
+			}
+			records = append(records, record)
+		}
+		return nil
+	})
+
+	return records, err
+}
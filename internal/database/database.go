@@ -1,7 +1,9 @@
 package database
 
 import (
+	"errors"
 	"log"
+	"time"
 
 	"github.com/brettcodling/SugarMateReader/internal/directory"
 	bolt "go.etcd.io/bbolt"
@@ -11,7 +13,10 @@ var DB *bolt.DB
 
 func init() {
 	var err error
-	DB, err = bolt.Open(directory.ConfigDir+"settings.db", 0600, nil)
+	DB, err = bolt.Open(directory.ConfigDir+"settings.db", 0600, &bolt.Options{Timeout: 2 * time.Second})
+	if errors.Is(err, bolt.ErrTimeout) {
+		log.Fatal("The database is in use, quit the running SugarMateReader first")
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	bolt "go.etcd.io/bbolt"
+)
+
+var readingsBucket = []byte("Readings")
+
+// readingKey gets the key for a reading, keys sort in time order.
+func readingKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+
+	return key
+}
+
+// SaveReadings stores the readings in the history, readings with the same time are replaced.
+func SaveReadings(readings []glucose.Reading) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(readingsBucket)
+		if err != nil {
+			return err
+		}
+		for _, reading := range readings {
+			if reading.Missing() || reading.Time.IsZero() {
+				continue
+			}
+			value, err := json.Marshal(reading)
+			if err != nil {
+				return err
+			}
+			err = b.Put(readingKey(reading.Time), value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ImportReadings stores the readings which aren't already in the history, so imported readings
+// never replace the ones fetched by the app. It gets the number of readings stored.
+func ImportReadings(readings []glucose.Reading) (int, error) {
+	imported := 0
+	err := DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(readingsBucket)
+		if err != nil {
+			return err
+		}
+		for _, reading := range readings {
+			key := readingKey(reading.Time)
+			if reading.Missing() || reading.Time.IsZero() || b.Get(key) != nil {
+				continue
+			}
+			value, err := json.Marshal(reading)
+			if err != nil {
+				return err
+			}
+			err = b.Put(key, value)
+			if err != nil {
+				return err
+			}
+			imported++
+		}
+		return nil
+	})
+
+	return imported, err
+}
+
+// GetReadings gets the stored readings between from and to, oldest first.
+func GetReadings(from, to time.Time) ([]glucose.Reading, error) {
+	readings := []glucose.Reading{}
+	err := DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(readingsBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		max := readingKey(to)
+		for k, v := c.Seek(readingKey(from)); k != nil && bytes.Compare(k, max) <= 0; k, v = c.Next() {
+			var reading glucose.Reading
+			err := json.Unmarshal(v, &reading)
+			if err != nil {
+				return err
+			}
+			readings = append(readings, reading)
+		}
+		return nil
+	})
+
+	return readings, err
+}
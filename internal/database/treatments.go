@@ -0,0 +1,79 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+	bolt "go.etcd.io/bbolt"
+)
+
+var treatmentsBucket = []byte("Treatments")
+
+// treatmentKey gets the key for a treatment, keys sort in time order. Treatments logged from the
+// dashboard only have the minute, so a number is added when another of the same type was logged in
+// the same minute rather than replacing it.
+func treatmentKey(b *bolt.Bucket, t treatment.Treatment) []byte {
+	key := append(readingKey(t.Time), t.Type...)
+	if t.Source != treatment.Source {
+		return key
+	}
+	base := key
+	for i := 2; b.Get(key) != nil; i++ {
+		key = append(slices.Clone(base), "#"+strconv.Itoa(i)...)
+	}
+
+	return key
+}
+
+// SaveTreatments stores the treatments. Treatments fetched or imported again replace the stored
+// treatment of the same type at the same time, treatments logged from the dashboard are kept.
+func SaveTreatments(treatments []treatment.Treatment) error {
+	return DB.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(treatmentsBucket)
+		if err != nil {
+			return err
+		}
+		for _, t := range treatments {
+			if t.Time.IsZero() {
+				continue
+			}
+			value, err := json.Marshal(t)
+			if err != nil {
+				return err
+			}
+			err = b.Put(treatmentKey(b, t), value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetTreatments gets the treatments between from and to, oldest first.
+func GetTreatments(from, to time.Time) ([]treatment.Treatment, error) {
+	treatments := []treatment.Treatment{}
+	err := DB.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(treatmentsBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		max := readingKey(to.Add(time.Nanosecond))
+		for k, v := c.Seek(readingKey(from)); k != nil && bytes.Compare(k, max) < 0; k, v = c.Next() {
+			var t treatment.Treatment
+			err := json.Unmarshal(v, &t)
+			if err != nil {
+				return err
+			}
+			treatments = append(treatments, t)
+		}
+		return nil
+	})
+
+	return treatments, err
+}
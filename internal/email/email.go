@@ -0,0 +1,203 @@
+package email
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+const (
+	// historyWindow is how far back the readings included in an email go.
+	historyWindow = 30 * time.Minute
+	// timeout is how long connecting to and talking to the SMTP server can take.
+	timeout = 30 * time.Second
+)
+
+// Limiter limits how often each alert is emailed.
+type Limiter struct {
+	sent  map[string]time.Time
+	mutex sync.Mutex
+}
+
+// NewLimiter creates a limiter which hasn't sent any emails.
+func NewLimiter() *Limiter {
+	return &Limiter{sent: map[string]time.Time{}}
+}
+
+// Allow reports whether the alert can be emailed now, recording that it was when it can.
+func (l *Limiter) Allow(rule string, interval time.Duration, now time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if sent, ok := l.sent[rule]; ok && now.Sub(sent) < interval {
+		return false
+	}
+	l.sent[rule] = now
+
+	return true
+}
+
+// Release forgets the email of the alert allowed at the time, so it can be retried after sending
+// it failed.
+func (l *Limiter) Release(rule string, allowed time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.sent[rule].Equal(allowed) {
+		delete(l.sent, rule)
+	}
+}
+
+// Listen emails every alert event when email is enabled, each alert is emailed at most once per
+// interval so a long low doesn't flood the inbox.
+func Listen(events <-chan alert.Event, setting func() settings.Setting, history func(from, to time.Time) ([]glucose.Reading, error)) {
+	limiter := NewLimiter()
+	for event := range events {
+		current := setting()
+		now := time.Now()
+		if current.Email.Enabled != "true" || !limiter.Allow(event.Rule, Interval(current.Email), now) {
+			continue
+		}
+		readings, err := history(event.Reading.Time.Add(-historyWindow), event.Reading.Time)
+		if err != nil {
+			log.Println("error:")
+			log.Println(err)
+		}
+		go func() {
+			err := Send(current.Email, Compose(event, readings, current, now))
+			if err != nil {
+				// the alert is emailed again with the next event rather than after the interval
+				limiter.Release(event.Rule, now)
+				log.Println("error:")
+				log.Println(err)
+			}
+		}()
+	}
+}
+
+// Interval gets how often each alert can be emailed.
+func Interval(config settings.Email) time.Duration {
+	if config.Interval == "" {
+		return 30 * time.Minute
+	}
+
+	return settings.Minutes(config.Interval)
+}
+
+// Recipients gets the addresses the emails are sent to.
+func Recipients(config settings.Email) []string {
+	var recipients []string
+	for _, address := range strings.Split(config.To, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			recipients = append(recipients, address)
+		}
+	}
+
+	return recipients
+}
+
+// Compose builds the email for the alert event along with the readings leading up to it.
+func Compose(event alert.Event, history []glucose.Reading, setting settings.Setting, now time.Time) []byte {
+	unit := glucose.UnitLabel(setting.Units)
+	var body bytes.Buffer
+	fmt.Fprintf(&body, "From: %s\r\n", setting.Email.From)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(Recipients(setting.Email), ", "))
+	fmt.Fprintf(&body, "Subject: SugarMateReader %s: %s\r\n", event.Title, event.Message)
+	fmt.Fprintf(&body, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	body.WriteString("MIME-Version: 1.0\r\n")
+	body.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	fmt.Fprintf(&body, "%s\r\n\r\n", event.Message)
+	fmt.Fprintf(&body, "Reading: %s %s %s at %s\r\n", setting.Number(glucose.Value(float64(event.Reading.MgDl), setting.Units), setting.Units), unit, event.Reading.Trend, event.Reading.Time.Local().Format(time.TimeOnly))
+	rate := glucose.Value(event.Reading.Rate*setting.Rate.Minutes(), setting.Units)
+	fmt.Fprintf(&body, "Rate: %s %s%s\r\n", setting.Change(rate, setting.RateFormat(setting.Units)), unit, setting.Rate.Label())
+	if event.Threshold != "" {
+		fmt.Fprintf(&body, "Threshold: %s\r\n", event.Threshold)
+	}
+	if len(history) > 0 {
+		fmt.Fprintf(&body, "\r\nLast %d minutes:\r\n", int(historyWindow.Minutes()))
+		for _, reading := range history {
+			if reading.Missing() {
+				continue
+			}
+			fmt.Fprintf(&body, "%s  %s %s\r\n", reading.Time.Local().Format("15:04"), setting.Number(glucose.Value(float64(reading.MgDl), setting.Units), setting.Units), unit)
+		}
+	}
+
+	return body.Bytes()
+}
+
+// Send sends the message through the SMTP server, the connection must be upgraded with STARTTLS
+// before the credentials are sent.
+func Send(config settings.Email, message []byte) error {
+	recipients := Recipients(config)
+	if len(recipients) == 0 {
+		return errors.New("no email recipients")
+	}
+	port := config.Port
+	if port == "" {
+		port = "587"
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(config.Host, port), timeout)
+	if err != nil {
+		return err
+	}
+	// the deadline covers the whole conversation so a stalled server doesn't hold up the alert
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if ok, _ := client.Extension("STARTTLS"); !ok {
+		return fmt.Errorf("smtp server %s does not support STARTTLS", config.Host)
+	}
+	err = client.StartTLS(&tls.Config{ServerName: config.Host})
+	if err != nil {
+		return err
+	}
+	if config.Username != "" {
+		err = client.Auth(smtp.PlainAuth("", config.Username, config.Password, config.Host))
+		if err != nil {
+			return err
+		}
+	}
+	err = client.Mail(config.From)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		err = client.Rcpt(recipient)
+		if err != nil {
+			return err
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(message)
+	if err != nil {
+		return err
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,126 @@
+package email
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter()
+	now := time.Now()
+	if !limiter.Allow("low", 30*time.Minute, now) {
+		t.Error("first low was not allowed")
+	}
+	if limiter.Allow("low", 30*time.Minute, now.Add(10*time.Minute)) {
+		t.Error("low was allowed again within the interval")
+	}
+	if !limiter.Allow("high", 30*time.Minute, now.Add(10*time.Minute)) {
+		t.Error("high was limited by the low")
+	}
+	if !limiter.Allow("low", 30*time.Minute, now.Add(30*time.Minute)) {
+		t.Error("low was not allowed after the interval")
+	}
+}
+
+func TestLimiterRelease(t *testing.T) {
+	limiter := NewLimiter()
+	now := time.Now()
+	limiter.Allow("low", 30*time.Minute, now)
+	limiter.Release("low", now)
+	if !limiter.Allow("low", 30*time.Minute, now.Add(5*time.Minute)) {
+		t.Error("low was not allowed again after failing to send")
+	}
+	// releasing an older email doesn't forget a newer one
+	limiter.Release("low", now)
+	if limiter.Allow("low", 30*time.Minute, now.Add(10*time.Minute)) {
+		t.Error("low was allowed again within the interval")
+	}
+}
+
+func TestCompose(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 30, 0, 0, time.Local)
+	setting := settings.Setting{
+		Units:   "mmol",
+		Format:  "%.1f",
+		Rate:    settings.Rate{Per: "5"},
+		Display: settings.Display{Separator: ","},
+		Email:   settings.Email{From: "reader@example.com", To: "a@example.com, b@example.com"},
+	}
+	event := alert.Event{
+		Title:     "ALERT!",
+		Message:   "LOW GLUCOSE",
+		Threshold: "4.0",
+		Reading:   glucose.Reading{Time: now, MgDl: 63, Trend: "FALLING", Rate: -1.8},
+	}
+	history := []glucose.Reading{
+		{Time: now.Add(-10 * time.Minute), MgDl: 81},
+		{Time: now.Add(-5 * time.Minute), MgDl: 72},
+	}
+
+	message := string(Compose(event, history, setting, now))
+	for _, want := range []string{
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: SugarMateReader ALERT!: LOW GLUCOSE\r\n",
+		"Reading: 3,5 mmol/L FALLING at 03:30:00\r\n",
+		"Rate: -0,5 mmol/L/5min\r\n",
+		"Threshold: 4.0\r\n",
+		"03:20  4,5 mmol/L\r\n",
+		"03:25  4,0 mmol/L\r\n",
+	} {
+		if !strings.Contains(message, want) {
+			t.Errorf("message is missing %q:\n%s", want, message)
+		}
+	}
+}
+
+func TestSendRequiresStartTLS(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost ESMTP\r\n"))
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "EHLO"):
+				conn.Write([]byte("250-localhost\r\n250 AUTH PLAIN\r\n"))
+			case strings.HasPrefix(line, "QUIT"):
+				conn.Write([]byte("221 bye\r\n"))
+				return
+			default:
+				t.Errorf("unexpected command %q", line)
+				conn.Write([]byte("500 no\r\n"))
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	err = Send(settings.Email{
+		Host:     host,
+		Port:     port,
+		Username: "user",
+		Password: "secret",
+		To:       "a@example.com",
+	}, []byte("Subject: test\r\n\r\ntest"))
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Errorf("err = %v, want STARTTLS error", err)
+	}
+}
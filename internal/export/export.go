@@ -0,0 +1,151 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+)
+
+// Formats are the formats readings and treatments can be exported to.
+var Formats = []string{"csv", "json", "ndjson"}
+
+// Row is an exported reading or treatment, Type is glucose for readings.
+type Row struct {
+	TimeUTC   string  `json:"time_utc"`
+	TimeLocal string  `json:"time_local"`
+	Type      string  `json:"type"`
+	Value     float64 `json:"value"`
+	Unit      string  `json:"unit"`
+	Trend     string  `json:"trend"`
+	Source    string  `json:"source"`
+	Note      string  `json:"note"`
+	time      time.Time
+}
+
+// header is the header row of a CSV export.
+var header = []string{"time_utc", "time_local", "type", "value", "unit", "trend", "source", "note"}
+
+// Rows converts the readings and treatments into rows in time order, with glucose values in the
+// units and local times in the location. Missing readings are skipped.
+func Rows(readings []glucose.Reading, treatments []treatment.Treatment, units string, location *time.Location) []Row {
+	rows := []Row{}
+	for _, reading := range readings {
+		if reading.Missing() {
+			continue
+		}
+		value := glucose.Round(float64(reading.MgDl), units)
+		rows = append(rows, Row{
+			TimeUTC:   reading.Time.UTC().Format(time.RFC3339),
+			TimeLocal: reading.Time.In(location).Format(time.RFC3339),
+			Type:      "glucose",
+			Value:     value,
+			Unit:      glucose.UnitLabel(units),
+			Trend:     reading.Trend,
+			Source:    reading.Source,
+			time:      reading.Time,
+		})
+	}
+	for _, t := range treatments {
+		rows = append(rows, Row{
+			TimeUTC:   t.Time.UTC().Format(time.RFC3339),
+			TimeLocal: t.Time.In(location).Format(time.RFC3339),
+			Type:      t.Type,
+			Value:     t.Amount,
+			Unit:      treatment.Unit(t.Type),
+			Source:    t.Source,
+			Note:      t.Note,
+			time:      t.Time,
+		})
+	}
+	slices.SortStableFunc(rows, func(a, b Row) int {
+		return a.time.Compare(b.time)
+	})
+
+	return rows
+}
+
+// Dates parses the first and last days of an export as YYYY-MM-DD in the location of now, into
+// the start of the first day and the end of the last. The range defaults to the last 14 days.
+func Dates(first, last string, now time.Time) (time.Time, time.Time, error) {
+	from := time.Date(now.Year(), now.Month(), now.Day()-13, 0, 0, 0, 0, now.Location())
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var err error
+	if first != "" {
+		from, err = time.ParseInLocation(time.DateOnly, first, now.Location())
+		if err != nil {
+			return from, to, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", first)
+		}
+	}
+	if last != "" {
+		to, err = time.ParseInLocation(time.DateOnly, last, now.Location())
+		if err != nil {
+			return from, to, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", last)
+		}
+	}
+
+	return from, to.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// ContentType gets the MIME type of the format.
+func ContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv"
+	case "ndjson":
+		return "application/x-ndjson"
+	}
+
+	return "application/json"
+}
+
+// Write writes the readings and treatments to w in the format.
+func Write(w io.Writer, format string, readings []glucose.Reading, treatments []treatment.Treatment, units string, location *time.Location) error {
+	rows := Rows(readings, treatments, units, location)
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		err := writer.Write(header)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			err = writer.Write([]string{
+				row.TimeUTC,
+				row.TimeLocal,
+				row.Type,
+				strconv.FormatFloat(row.Value, 'f', -1, 64),
+				row.Unit,
+				row.Trend,
+				row.Source,
+				row.Note,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(rows)
+	case "ndjson":
+		encoder := json.NewEncoder(w)
+		for _, row := range rows {
+			err := encoder.Encode(row)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown export format %q, expected csv, json or ndjson", format)
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+)
+
+var (
+	location = time.FixedZone("AEST", 10*60*60)
+	readings = []glucose.Reading{
+		{Time: time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC), MgDl: 100, Trend: "FLAT", Source: "sugarmate"},
+		{Time: time.Date(2024, 3, 1, 22, 5, 0, 0, time.UTC)},
+		{Time: time.Date(2024, 3, 1, 22, 10, 0, 0, time.UTC), MgDl: 130, Trend: "UP", Source: "nightscout"},
+	}
+)
+
+func TestWriteCSV(t *testing.T) {
+	buf := new(bytes.Buffer)
+	treatments := []treatment.Treatment{
+		{Time: time.Date(2024, 3, 1, 22, 5, 0, 0, time.UTC), Type: treatment.Carbs, Amount: 30, Note: "toast, jam", Source: "local"},
+	}
+	err := Write(buf, "csv", readings, treatments, "mmol", location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "time_utc,time_local,type,value,unit,trend,source,note\n" +
+		"2024-03-01T22:00:00Z,2024-03-02T08:00:00+10:00,glucose,5.5,mmol/L,FLAT,sugarmate,\n" +
+		"2024-03-01T22:05:00Z,2024-03-02T08:05:00+10:00,carbs,30,g,,local,\"toast, jam\"\n" +
+		"2024-03-01T22:10:00Z,2024-03-02T08:10:00+10:00,glucose,7.2,mmol/L,UP,nightscout,\n"
+	if buf.String() != want {
+		t.Errorf("csv =\n%s\nwant\n%s", buf, want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	for _, format := range []string{"json", "ndjson"} {
+		buf := new(bytes.Buffer)
+		err := Write(buf, format, readings, nil, "mgdl", location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rows []Row
+		if format == "json" {
+			err = json.Unmarshal(buf.Bytes(), &rows)
+		} else {
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var row Row
+				err = json.Unmarshal([]byte(line), &row)
+				rows = append(rows, row)
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 2 || rows[1].Value != 130 || rows[1].Unit != "mg/dL" || rows[1].Type != "glucose" || rows[1].TimeLocal != "2024-03-02T08:10:00+10:00" {
+			t.Errorf("%s rows = %+v", format, rows)
+		}
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if Write(new(bytes.Buffer), "xml", readings, nil, "mgdl", location) == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestDates(t *testing.T) {
+	now := time.Date(2024, 3, 14, 15, 30, 0, 0, location)
+	from, to, err := Dates("", "", now)
+	if err != nil || !from.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, location)) || to.Day() != 14 || to.Hour() != 23 {
+		t.Errorf("default dates = %s, %s, %v", from, to, err)
+	}
+	from, to, err = Dates("2024-02-01", "2024-02-01", now)
+	if err != nil || to.Sub(from) != 24*time.Hour-time.Nanosecond {
+		t.Errorf("dates = %s, %s, %v", from, to, err)
+	}
+	_, _, err = Dates("1 Feb", "", now)
+	if err == nil {
+		t.Error("expected an error for an invalid date")
+	}
+}
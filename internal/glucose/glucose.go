@@ -0,0 +1,150 @@
+package glucose
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MgDlPerMmol is the factor used to convert between mg/dl and mmol/l, from the molar mass of
+// glucose.
+const MgDlPerMmol = 18.0182
+
+// Source is the source of readings fetched from the SugarMate API.
+const Source = "sugarmate"
+
+// Reading is a single glucose reading along with how it is changing.
+type Reading struct {
+	Time  time.Time `json:"time"`
+	MgDl  int       `json:"mg_dl"`
+	Trend string    `json:"trend"`
+	// Delta is the difference from the previous reading, whatever the gap between them.
+	Delta int `json:"delta"`
+	// Rate is the smoothed rate of change in mg/dl per minute.
+	Rate   float64 `json:"rate"`
+	Source string  `json:"source"`
+}
+
+// Missing reports whether the reading holds no glucose value.
+func (r Reading) Missing() bool {
+	return r.MgDl < 1
+}
+
+// Value converts a mg/dl value into the given units.
+func Value(mgdl float64, units string) float64 {
+	if units == "mmol" {
+		return mgdl / MgDlPerMmol
+	}
+
+	return mgdl
+}
+
+// Round converts a mg/dl value into the given units, rounded to a whole mg/dl or a tenth of a
+// mmol/l as it's shown by default.
+func Round(mgdl float64, units string) float64 {
+	if units == "mmol" {
+		return math.Round(Value(mgdl, units)*10) / 10
+	}
+
+	return math.Round(mgdl)
+}
+
+// MgDl converts a value in the given units into mg/dl.
+func MgDl(value float64, units string) float64 {
+	if units == "mmol" {
+		return value * MgDlPerMmol
+	}
+
+	return value
+}
+
+// UnitLabel gets the display name of the units.
+func UnitLabel(units string) string {
+	if units == "mgdl" {
+		return "mg/dL"
+	}
+
+	return "mmol/L"
+}
+
+// Arrow gets the arrow showing the direction of a trend, or an ellipsis when it isn't known.
+func Arrow(trend string) string {
+	switch true {
+	case strings.Contains(trend, "FORTY_FIVE_UP"):
+		return "↗"
+	case strings.Contains(trend, "DOUBLE_UP"):
+		return "↑↑"
+	case strings.Contains(trend, "UP"):
+		return "↑"
+	case strings.Contains(trend, "FORTY_FIVE_DOWN"):
+		return "↘"
+	case strings.Contains(trend, "DOUBLE_DOWN"):
+		return "↓↓"
+	case strings.Contains(trend, "DOWN"):
+		return "↓"
+	case strings.Contains(trend, "FLAT"):
+		return "→"
+	}
+
+	return "..."
+}
+
+// TrendDescription describes the direction of a trend in words.
+func TrendDescription(trend string) string {
+	switch trend {
+	case "DOUBLE_UP":
+		return "rising quickly"
+	case "SINGLE_UP":
+		return "rising"
+	case "FORTY_FIVE_UP":
+		return "rising slowly"
+	case "FLAT":
+		return "steady"
+	case "FORTY_FIVE_DOWN":
+		return "falling slowly"
+	case "SINGLE_DOWN":
+		return "falling"
+	case "DOUBLE_DOWN":
+		return "falling quickly"
+	}
+
+	return "trend unknown"
+}
+
+// Rate calculates the rate of change in mg/dl per minute over the readings within the window
+// before the newest reading. The median of the rates between every pair of readings is used so
+// a single noisy reading doesn't skew the result, and readings missed by the sensor just widen
+// the gap the rate is normalised over.
+func Rate(readings []Reading, window time.Duration) (float64, bool) {
+	var newest time.Time
+	for _, reading := range readings {
+		if !reading.Missing() && reading.Time.After(newest) {
+			newest = reading.Time
+		}
+	}
+	recent := slices.DeleteFunc(slices.Clone(readings), func(reading Reading) bool {
+		return reading.Missing() || newest.Sub(reading.Time) > window
+	})
+
+	var rates []float64
+	for i := range recent {
+		for j := i + 1; j < len(recent); j++ {
+			minutes := recent[j].Time.Sub(recent[i].Time).Minutes()
+			if minutes == 0 {
+				continue
+			}
+			rates = append(rates, float64(recent[j].MgDl-recent[i].MgDl)/minutes)
+		}
+	}
+	if len(rates) == 0 {
+		return 0, false
+	}
+	slices.Sort(rates)
+	middle := len(rates) / 2
+	if len(rates)%2 == 0 {
+		return (rates[middle-1] + rates[middle]) / 2, true
+	}
+
+	return rates[middle], true
+}
@@ -0,0 +1,72 @@
+package glucose
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRate(t *testing.T) {
+	now := time.Now()
+	at := func(minutes, mgdl int) Reading {
+		return Reading{Time: now.Add(time.Duration(minutes) * time.Minute), MgDl: mgdl}
+	}
+
+	tests := []struct {
+		name     string
+		readings []Reading
+		want     float64
+		ok       bool
+	}{
+		{"steady", []Reading{at(-10, 100), at(-5, 105), at(0, 110)}, 1, true},
+		{"missed reading", []Reading{at(-10, 100), at(0, 110)}, 1, true},
+		{"outlier", []Reading{at(-15, 100), at(-10, 105), at(-5, 160), at(0, 115)}, 1, true},
+		{"outside window", []Reading{at(-30, 40), at(-5, 105), at(0, 110)}, 1, true},
+		{"single reading", []Reading{at(0, 110)}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := Rate(tt.readings, 15*time.Minute)
+			if ok != tt.ok || math.Abs(got-tt.want) > 0.001 {
+				t.Errorf("expected %v %v, got %v %v", tt.want, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestArrow(t *testing.T) {
+	tests := map[string]string{
+		"DOUBLE_UP":       "↑↑",
+		"SINGLE_UP":       "↑",
+		"FORTY_FIVE_UP":   "↗",
+		"FLAT":            "→",
+		"FORTY_FIVE_DOWN": "↘",
+		"SINGLE_DOWN":     "↓",
+		"DOUBLE_DOWN":     "↓↓",
+		"NOT_COMPUTABLE":  "...",
+		"":                "...",
+	}
+	for trend, want := range tests {
+		if got := Arrow(trend); got != want {
+			t.Errorf("Arrow(%q) = %q, want %q", trend, got, want)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	tests := []struct {
+		mgdl  float64
+		units string
+		want  float64
+	}{
+		{100, "mmol", 5.5},
+		{180, "mmol", 10},
+		{72, "mmol", 4},
+		{112.4, "mgdl", 112},
+	}
+	for _, tt := range tests {
+		if got := Round(tt.mgdl, tt.units); got != tt.want {
+			t.Errorf("Round(%v, %q) = %v, want %v", tt.mgdl, tt.units, got, tt.want)
+		}
+	}
+}
@@ -2,188 +2,187 @@ package img
 
 import (
 	"bytes"
-	"fmt"
 	"image"
-	"log"
+	"image/color"
 	"math"
-	"strconv"
-	"strings"
 
 	"github.com/brettcodling/SugarMateReader/internal/directory"
-	"github.com/brettcodling/SugarMateReader/internal/notify"
-	"github.com/brettcodling/SugarMateReader/internal/ui"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
 	"github.com/fogleman/gg"
 )
 
-// BuildImage builds the entire reading image which is used as the systray icon.
-func BuildImage(value int, trend string, delta int) []byte {
-	fullContext := gg.NewContext(180, 50)
-	valueImage, err := getImageValue(value)
-	if err != nil {
-		notify.Warning("ERROR!", err.Error())
-		log.Println("error:")
-		log.Println(err)
-	} else {
-		if valueImage.Bounds().Max.X > 0 || valueImage.Bounds().Max.Y > 0 {
-			fullContext.DrawImageAnchored(valueImage, 40, 25, 0.5, 0.5)
-		}
+// Theme holds the fonts and colours used to draw the reading image.
+type Theme struct {
+	ValueFont  string
+	SymbolFont string
+	Text       color.Color
+	InRange    color.Color
+	Low        color.Color
+	High       color.Color
+	FastChange color.Color
+}
+
+// DefaultTheme gets the theme using the fonts installed in the config directory.
+func DefaultTheme() Theme {
+	return Theme{
+		ValueFont:  directory.ConfigDir + "Roboto-Bold.ttf",
+		SymbolFont: directory.ConfigDir + "NotoSansSymbols.ttf",
+		Text:       color.White,
+		InRange:    color.RGBA{0, 255, 0, 255},
+		Low:        color.RGBA{255, 0, 0, 255},
+		High:       color.RGBA{255, 127, 0, 255},
+		FastChange: color.RGBA{255, 0, 0, 255},
 	}
-	trendImage, err := getImageTrend(trend)
+}
+
+// BuildImage builds the entire reading image which is used as the systray icon. The image is
+// widened when the value doesn't fit, such as when both units are shown.
+func BuildImage(reading glucose.Reading, setting settings.Setting, theme Theme) ([]byte, error) {
+	valueImage, err := getImageValue(reading, setting, theme)
 	if err != nil {
-		notify.Warning("ERROR!", err.Error())
-		log.Println("error:")
-		log.Println(err)
-	} else {
-		if trendImage.Bounds().Max.X > 0 || trendImage.Bounds().Max.Y > 0 {
-			fullContext.DrawImageAnchored(trendImage, 90, 25, 0.5, 0.5)
-		}
+		return nil, err
 	}
-	deltaImage, err := getImageDelta(delta)
+	valueWidth := valueImage.Bounds().Dx()
+	fullContext := gg.NewContext(valueWidth+100, 50)
+	fullContext.DrawImageAnchored(valueImage, valueWidth/2, 25, 0.5, 0.5)
+	trendImage, err := getImageTrend(reading.Trend, theme)
 	if err != nil {
-		notify.Warning("ERROR!", err.Error())
-		log.Println("error:")
-		log.Println(err)
-	} else if deltaImage != nil {
-		if deltaImage.Bounds().Max.X > 0 || deltaImage.Bounds().Max.Y > 0 {
-			fullContext.DrawImageAnchored(deltaImage, 140, 25, 0.5, 0.5)
+		return nil, err
+	}
+	fullContext.DrawImageAnchored(trendImage, valueWidth+10, 25, 0.5, 0.5)
+	if !reading.Missing() {
+		deltaImage, err := getImageDelta(reading.Rate, setting, theme)
+		if err != nil {
+			return nil, err
 		}
+		fullContext.DrawImageAnchored(deltaImage, valueWidth+60, 25, 0.5, 0.5)
 	}
 	buf := new(bytes.Buffer)
-	fullContext.EncodePNG(buf)
+	err = fullContext.EncodePNG(buf)
+	if err != nil {
+		return nil, err
+	}
 
-	return buf.Bytes()
+	return buf.Bytes(), nil
 }
 
 // getImageContext gets an image context which can be used to build individual images.
-func getImageContext(value, font string, fontSize, red, green, blue float64) *gg.Context {
-	context := gg.NewContext(80, 50)
+func getImageContext(value, font string, fontSize float64, colour color.Color) (*gg.Context, error) {
+	return getImageContextWidth(value, font, fontSize, colour, 80)
+}
+
+// getImageContextWidth gets an image context of the given width, the value is drawn 10px left of
+// the centre.
+func getImageContextWidth(value, font string, fontSize float64, colour color.Color, width int) (*gg.Context, error) {
+	context := gg.NewContext(width, 50)
 	context.SetRGBA(0, 0, 0, 0)
 	context.Clear()
-	context.SetRGB(red, green, blue)
+	context.SetColor(colour)
 	if fontSize == 0 {
 		fontSize = 32
 	}
-	switch font {
-	case "noto":
-		font = directory.ConfigDir + "NotoSansSymbols.ttf"
-	default:
-		font = directory.ConfigDir + "Roboto-Bold.ttf"
+	err := context.LoadFontFace(font, fontSize)
+	if err != nil {
+		return nil, err
 	}
-	context.LoadFontFace(font, fontSize)
-	context.DrawStringAnchored(value, 30, 25, 0.5, 0.5)
+	context.DrawStringAnchored(value, float64(width)/2-10, 25, 0.5, 0.5)
 
-	return context
+	return context, nil
 }
 
-// getImageDelta gets the delta image.
-func getImageDelta(delta int) (image.Image, error) {
-	change := float64(delta)
-	if ui.Settings.Units == "mmol" {
-		change = change / 18
-	}
-	red := 1.0
-	green := 1.0
-	blue := 1.0
-	fastChange, err := strconv.ParseFloat(ui.Settings.Alerts.FastChange, 64)
+// getImageDelta gets the delta image, the rate of change labelled with the time it covers.
+func getImageDelta(rate float64, setting settings.Setting, theme Theme) (image.Image, error) {
+	change := glucose.Value(rate*setting.Rate.Minutes(), setting.Units)
+	colour := theme.Text
+	fastChange, err := settings.Level(setting.Alerts.FastChange)
 	if err != nil {
 		return nil, err
 	}
-	if math.Abs(change) >= fastChange {
-		green = 0
-		blue = 0
-		if ui.Settings.Alerts.FastChangeEnabled == "true" {
-			if change > 0 {
-				notify.Warning("ALERT!", "RISING FAST")
-			} else {
-				notify.Warning("ALERT!", "FALLING FAST")
-			}
-		}
+	if setting.Round(math.Abs(rate*5)) >= setting.Round(fastChange) {
+		colour = theme.FastChange
 	}
-
-	context := getImageContext(fmt.Sprintf(ui.Settings.Format, change), "roboto", 26, red, green, blue)
-	buf := new(bytes.Buffer)
-	context.EncodePNG(buf)
-	deltaImage, _, err := image.Decode(buf)
+	context, err := getImageContext("", theme.ValueFont, 26, colour)
+	if err != nil {
+		return nil, err
+	}
+	context.DrawStringAnchored(setting.Change(change, setting.RateFormat(setting.Units)), 30, 20, 0.5, 0.5)
+	err = context.LoadFontFace(theme.ValueFont, 12)
 	if err != nil {
 		return nil, err
 	}
+	context.DrawStringAnchored(setting.Rate.Label(), 30, 42, 0.5, 0.5)
 
-	return deltaImage, nil
+	return context.Image(), nil
 }
 
 // getImageTrend gets the trend image.
-func getImageTrend(trend string) (image.Image, error) {
-	switch true {
-	case strings.Contains(trend, "FORTY_FIVE_UP"):
-		trend = "↗"
-	case strings.Contains(trend, "DOUBLE_UP"):
-		trend = "↑↑"
-	case strings.Contains(trend, "UP"):
-		trend = "↑"
-	case strings.Contains(trend, "FORTY_FIVE_DOWN"):
-		trend = "↘"
-	case strings.Contains(trend, "DOUBLE_DOWN"):
-		trend = "↓↓"
-	case strings.Contains(trend, "DOWN"):
-		trend = "↓"
-	case strings.Contains(trend, "FLAT"):
-		trend = "→"
-	default:
-		trend = "..."
-	}
-
-	context := getImageContext(trend, "noto", 32, 1, 1, 1)
-	buf := new(bytes.Buffer)
-	context.EncodePNG(buf)
-	trendImage, _, err := image.Decode(buf)
+func getImageTrend(trend string, theme Theme) (image.Image, error) {
+	context, err := getImageContext(glucose.Arrow(trend), theme.SymbolFont, 32, theme.Text)
 	if err != nil {
 		return nil, err
 	}
 
-	return trendImage, nil
+	return context.Image(), nil
 }
 
 // getImageValue gets the value image.
-func getImageValue(value int) (image.Image, error) {
-	floatValue := float64(value)
-	if ui.Settings.Units == "mmol" {
-		floatValue = floatValue / 18
+func getImageValue(reading glucose.Reading, setting settings.Setting, theme Theme) (image.Image, error) {
+	if reading.Missing() {
+		context, err := getImageContext("---", theme.ValueFont, 32, theme.Text)
+		if err != nil {
+			return nil, err
+		}
+
+		return context.Image(), nil
 	}
-	err := notify.AlertLow(ui.Settings.Alerts.LowEnabled == "true", floatValue, ui.Settings.Alerts.Low)
+
+	value := setting.Round(float64(reading.MgDl))
+	colour := theme.InRange
+	lowRangeLevel, err := settings.Level(setting.Range.Low)
 	if err != nil {
 		return nil, err
 	}
-	err = notify.AlertHigh(ui.Settings.Alerts.HighEnabled == "true", floatValue, ui.Settings.Alerts.High)
+	highRangeLevel, err := settings.Level(setting.Range.High)
 	if err != nil {
 		return nil, err
 	}
+	if value < setting.Round(lowRangeLevel) {
+		colour = theme.Low
+	} else if value >= setting.Round(highRangeLevel) {
+		colour = theme.High
+	}
 
-	red := 0.0
-	green := 1.0
-	blue := 0.0
-	lowRangeLevel, err := strconv.ParseFloat(ui.Settings.Range.Low, 64)
+	text := setting.Value(float64(reading.MgDl))
+	fontSize := 32.0
+	if setting.Display.Both == "true" {
+		fontSize = 26
+	}
+	width, err := valueWidth(text, theme.ValueFont, fontSize)
 	if err != nil {
 		return nil, err
 	}
-	highRangeLevel, err := strconv.ParseFloat(ui.Settings.Range.High, 64)
+	context, err := getImageContextWidth(text, theme.ValueFont, fontSize, colour, width)
 	if err != nil {
 		return nil, err
 	}
-	if floatValue < lowRangeLevel {
-		green = 0
-		red = 1
-	} else if floatValue >= highRangeLevel {
-		green = 0.5
-		red = 1
-	}
 
-	context := getImageContext(fmt.Sprintf(ui.Settings.Format, floatValue), "roboto", 32, red, green, blue)
-	buf := new(bytes.Buffer)
-	context.EncodePNG(buf)
-	valueImage, _, err := image.Decode(buf)
+	return context.Image(), nil
+}
+
+// valueWidth gets the width of the value image, values wider than four digits are given a
+// wider image with a margin.
+func valueWidth(value, font string, fontSize float64) (int, error) {
+	context := gg.NewContext(1, 1)
+	err := context.LoadFontFace(font, fontSize)
 	if err != nil {
-		return nil, err
+		return 0, err
+	}
+	width, _ := context.MeasureString(value)
+
+	if width <= 64 {
+		return 80, nil
 	}
 
-	return valueImage, nil
+	return int(math.Ceil(width)) + 20, nil
 }
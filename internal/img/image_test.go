@@ -0,0 +1,135 @@
+package img
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+var update = flag.Bool("update", false, "update the golden images in testdata")
+
+func testTheme() Theme {
+	theme := DefaultTheme()
+	theme.ValueFont = "../../assets/Roboto-Bold.ttf"
+	theme.SymbolFont = "../../assets/NotoSansSymbols.ttf"
+
+	return theme
+}
+
+func mmolSetting() settings.Setting {
+	return settings.Setting{
+		Alerts: settings.Alert{FastChange: "9"},
+		Format: "%.1f",
+		Range:  settings.Range{Low: "81", High: "180"},
+		Units:  "mmol",
+	}
+}
+
+func mgdlSetting() settings.Setting {
+	return settings.Setting{
+		Alerts: settings.Alert{FastChange: "9"},
+		Format: "%.0f",
+		Range:  settings.Range{Low: "81", High: "180"},
+		Units:  "mgdl",
+	}
+}
+
+func perMinute(setting settings.Setting) settings.Setting {
+	setting.Rate.Per = "1"
+
+	return setting
+}
+
+func display(setting settings.Setting, display settings.Display) settings.Setting {
+	setting.Display = display
+	setting.Format = settings.ValueFormat(setting.Units, display.Decimals)
+
+	return setting
+}
+
+func TestBuildImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		reading glucose.Reading
+		setting settings.Setting
+	}{
+		{"trend_double_up", glucose.Reading{MgDl: 120, Trend: "DOUBLE_UP", Rate: 0.8}, mmolSetting()},
+		{"trend_single_up", glucose.Reading{MgDl: 120, Trend: "SINGLE_UP", Rate: 0.8}, mmolSetting()},
+		{"trend_forty_five_up", glucose.Reading{MgDl: 120, Trend: "FORTY_FIVE_UP", Rate: 0.8}, mmolSetting()},
+		{"trend_flat", glucose.Reading{MgDl: 120, Trend: "FLAT", Rate: 0}, mmolSetting()},
+		{"trend_forty_five_down", glucose.Reading{MgDl: 120, Trend: "FORTY_FIVE_DOWN", Rate: -0.8}, mmolSetting()},
+		{"trend_single_down", glucose.Reading{MgDl: 120, Trend: "SINGLE_DOWN", Rate: -0.8}, mmolSetting()},
+		{"trend_double_down", glucose.Reading{MgDl: 120, Trend: "DOUBLE_DOWN", Rate: -0.8}, mmolSetting()},
+		{"trend_not_computable", glucose.Reading{MgDl: 120, Trend: "NOT_COMPUTABLE", Rate: 0}, mmolSetting()},
+		{"trend_unknown", glucose.Reading{MgDl: 120, Trend: "", Rate: 0}, mmolSetting()},
+		{"mmol_low", glucose.Reading{MgDl: 70, Trend: "FLAT", Rate: 0}, mmolSetting()},
+		{"mmol_in_range", glucose.Reading{MgDl: 110, Trend: "FLAT", Rate: 0.4}, mmolSetting()},
+		{"mmol_high", glucose.Reading{MgDl: 200, Trend: "FLAT", Rate: 0.4}, mmolSetting()},
+		{"mmol_fast_fall", glucose.Reading{MgDl: 110, Trend: "DOUBLE_DOWN", Rate: -2.4}, mmolSetting()},
+		{"mgdl_low", glucose.Reading{MgDl: 70, Trend: "FLAT", Rate: 0}, mgdlSetting()},
+		{"mgdl_in_range", glucose.Reading{MgDl: 110, Trend: "FLAT", Rate: 0.4}, mgdlSetting()},
+		{"mgdl_high", glucose.Reading{MgDl: 200, Trend: "FLAT", Rate: 0.4}, mgdlSetting()},
+		{"mgdl_negative_delta", glucose.Reading{MgDl: 110, Trend: "FORTY_FIVE_DOWN", Rate: -1}, mgdlSetting()},
+		{"mgdl_fast_rise", glucose.Reading{MgDl: 110, Trend: "DOUBLE_UP", Rate: 2.4}, mgdlSetting()},
+		{"mgdl_per_minute", glucose.Reading{MgDl: 110, Trend: "FORTY_FIVE_UP", Rate: 1.2}, perMinute(mgdlSetting())},
+		{"mmol_per_minute", glucose.Reading{MgDl: 110, Trend: "FORTY_FIVE_DOWN", Rate: -1.2}, perMinute(mmolSetting())},
+		{"missing", glucose.Reading{}, mmolSetting()},
+		{"mmol_both", glucose.Reading{MgDl: 112, Trend: "FLAT", Rate: 0.4}, display(mmolSetting(), settings.Display{Both: "true"})},
+		{"mgdl_both", glucose.Reading{MgDl: 112, Trend: "FLAT", Rate: 0.4}, display(mgdlSetting(), settings.Display{Both: "true"})},
+		{"mmol_decimals_sign", glucose.Reading{MgDl: 112, Trend: "FORTY_FIVE_UP", Rate: 0.4}, display(mmolSetting(), settings.Display{Decimals: "2", Sign: "true"})},
+		{"mmol_comma", glucose.Reading{MgDl: 112, Trend: "FORTY_FIVE_DOWN", Rate: -0.4}, display(mmolSetting(), settings.Display{Both: "true", Separator: ","})},
+		{"missing_both", glucose.Reading{}, display(mmolSetting(), settings.Display{Both: "true"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildImage(tt.reading, tt.setting, testTheme())
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.name+".png")
+			if *update {
+				err := os.WriteFile(golden, got, 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decode(t, got).Pix, decode(t, want).Pix) {
+				t.Errorf("image does not match %s, run with -update to regenerate", golden)
+			}
+		})
+	}
+}
+
+func TestBuildImageInvalidSetting(t *testing.T) {
+	setting := mmolSetting()
+	setting.Range.Low = ""
+	_, err := BuildImage(glucose.Reading{MgDl: 120, Trend: "FLAT"}, setting, testTheme())
+	if err == nil {
+		t.Error("expected an error for an invalid range")
+	}
+}
+
+func decode(t *testing.T, data []byte) *image.RGBA {
+	t.Helper()
+	decoded, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba := image.NewRGBA(decoded.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+
+	return rgba
+}
@@ -0,0 +1,378 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+)
+
+const (
+	// minMgDl and maxMgDl are the lowest and highest levels sensors report, Clarity exports the
+	// readings past them as Low and High.
+	minMgDl = 40
+	maxMgDl = 400
+	// minValid and maxValid are the bounds outside which a value is rejected as invalid.
+	minValid = 20
+	maxValid = 600
+	// DuplicateWindow is how close readings from different sources can be and still be the same
+	// reading, half the 5 minute cadence. Exports round the times of the readings.
+	DuplicateWindow = 150 * time.Second
+)
+
+// Formats are the export formats which can be imported.
+var Formats = []string{"clarity", "libreview", "nightscout"}
+
+// Result is the readings parsed from an export along with the rows which couldn't be imported.
+type Result struct {
+	Readings []glucose.Reading
+	// Skipped is the number of rows which don't hold a glucose reading, such as insulin or
+	// calibration events.
+	Skipped int
+	Errors  []RowError
+}
+
+// RowError is a row of an export which holds an invalid reading.
+type RowError struct {
+	// Row is the line of a CSV export or the position of a Nightscout entry.
+	Row int
+	Err error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Row, e.Err)
+}
+
+// Detect works out the format of an export from its contents.
+func Detect(data []byte) (string, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return "nightscout", nil
+	case bytes.Contains(trimmed, []byte("Glucose Value (")):
+		return "clarity", nil
+	case bytes.Contains(trimmed, []byte("Historic Glucose")):
+		return "libreview", nil
+	}
+
+	return "", errors.New("unrecognised export, expected a Dexcom Clarity or LibreView CSV or a Nightscout JSON export")
+}
+
+// Parse parses an export in the format, times without a zone are read in the location.
+func Parse(format string, data []byte, location *time.Location) (Result, error) {
+	var result Result
+	var err error
+	switch format {
+	case "clarity":
+		result, err = Clarity(bytes.NewReader(data), location)
+	case "libreview":
+		result, err = LibreView(bytes.NewReader(data), location)
+	case "nightscout":
+		result, err = Nightscout(bytes.NewReader(data))
+	default:
+		return result, fmt.Errorf("unknown import format %q, expected one of: %s", format, strings.Join(Formats, ", "))
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Readings = deduplicate(result.Readings)
+
+	return result, nil
+}
+
+// Clarity parses a Dexcom Clarity CSV export.
+func Clarity(r io.Reader, location *time.Location) (Result, error) {
+	var result Result
+	rows, columns, err := readCSV(r, "Timestamp")
+	if err != nil {
+		return result, err
+	}
+	timestamp, eventType := columns["Timestamp"], columns["Event Type"]
+	value, units, err := valueColumn(columns, "Glucose Value (mg/dL)", "Glucose Value (mmol/L)")
+	if err != nil {
+		return result, err
+	}
+	rate, hasRate := columns["Glucose Rate of Change (mg/dL/min)"]
+	for _, row := range rows {
+		if field(row.fields, eventType) != "EGV" {
+			result.Skipped++
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02T15:04:05", field(row.fields, timestamp), location)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{row.line, fmt.Errorf("invalid timestamp %q", field(row.fields, timestamp))})
+			continue
+		}
+		mgdl, err := parseValue(field(row.fields, value), units)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{row.line, err})
+			continue
+		}
+		reading := glucose.Reading{Time: t, MgDl: mgdl, Source: "clarity"}
+		if hasRate {
+			reading.Rate, _ = strconv.ParseFloat(field(row.fields, rate), 64)
+		}
+		result.Readings = append(result.Readings, reading)
+	}
+
+	return result, nil
+}
+
+// LibreView parses a LibreView CSV export, both the historic and the scanned readings are imported.
+func LibreView(r io.Reader, location *time.Location) (Result, error) {
+	var result Result
+	rows, columns, err := readCSV(r, "Device Timestamp")
+	if err != nil {
+		return result, err
+	}
+	timestamp, recordType := columns["Device Timestamp"], columns["Record Type"]
+	historic, units, err := valueColumn(columns, "Historic Glucose mg/dL", "Historic Glucose mmol/L")
+	if err != nil {
+		return result, err
+	}
+	scan, _, err := valueColumn(columns, "Scan Glucose mg/dL", "Scan Glucose mmol/L")
+	if err != nil {
+		return result, err
+	}
+	for _, row := range rows {
+		value := historic
+		switch field(row.fields, recordType) {
+		case "0":
+		case "1":
+			value = scan
+		default:
+			result.Skipped++
+			continue
+		}
+		t, err := parseLibreViewTime(field(row.fields, timestamp), location)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{row.line, err})
+			continue
+		}
+		mgdl, err := parseValue(field(row.fields, value), units)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{row.line, err})
+			continue
+		}
+		result.Readings = append(result.Readings, glucose.Reading{Time: t, MgDl: mgdl, Source: "libreview"})
+	}
+
+	return result, nil
+}
+
+// entry is a Nightscout entry.
+type entry struct {
+	Type       string  `json:"type"`
+	SGV        float64 `json:"sgv"`
+	Date       int64   `json:"date"`
+	DateString string  `json:"dateString"`
+	Direction  string  `json:"direction"`
+}
+
+// directions maps the Nightscout trend directions to the SugarMate trends.
+var directions = map[string]string{
+	"DoubleUp":       "DOUBLE_UP",
+	"SingleUp":       "SINGLE_UP",
+	"FortyFiveUp":    "FORTY_FIVE_UP",
+	"Flat":           "FLAT",
+	"FortyFiveDown":  "FORTY_FIVE_DOWN",
+	"SingleDown":     "SINGLE_DOWN",
+	"DoubleDown":     "DOUBLE_DOWN",
+	"NOT COMPUTABLE": "NOT_COMPUTABLE",
+}
+
+// Nightscout parses a Nightscout JSON dump of entries, only the sensor glucose entries are imported.
+func Nightscout(r io.Reader) (Result, error) {
+	var result Result
+	var entries []json.RawMessage
+	err := json.NewDecoder(r).Decode(&entries)
+	if err != nil {
+		return result, err
+	}
+	for i, raw := range entries {
+		var e entry
+		err := json.Unmarshal(raw, &e)
+		if err != nil {
+			result.Errors = append(result.Errors, RowError{i + 1, err})
+			continue
+		}
+		if e.Type != "sgv" {
+			result.Skipped++
+			continue
+		}
+		var t time.Time
+		if e.Date > 0 {
+			t = time.UnixMilli(e.Date)
+		} else {
+			t, err = time.Parse(time.RFC3339Nano, e.DateString)
+			if err != nil {
+				result.Errors = append(result.Errors, RowError{i + 1, fmt.Errorf("invalid date %q", e.DateString)})
+				continue
+			}
+		}
+		if e.SGV < minValid || e.SGV > maxValid {
+			result.Errors = append(result.Errors, RowError{i + 1, fmt.Errorf("glucose value %g out of range", e.SGV)})
+			continue
+		}
+		result.Readings = append(result.Readings, glucose.Reading{
+			Time:   t,
+			MgDl:   int(math.Round(e.SGV)),
+			Trend:  directions[e.Direction],
+			Source: "nightscout",
+		})
+	}
+
+	return result, nil
+}
+
+// csvRow is a row of a CSV export along with the line it starts on.
+type csvRow struct {
+	line   int
+	fields []string
+}
+
+// readCSV reads the rows after the header row, which is the first row holding the column, along
+// with the index of each header column.
+func readCSV(r io.Reader, column string) ([]csvRow, map[string]int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	var rows []csvRow
+	var columns map[string]int
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if columns != nil {
+			line, _ := reader.FieldPos(0)
+			rows = append(rows, csvRow{line, fields})
+			continue
+		}
+		header := map[string]int{}
+		for i, name := range fields {
+			name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+			header[name] = i
+			// the Clarity timestamp column names its layout, as in Timestamp (YYYY-MM-DDThh:mm:ss)
+			if strings.HasPrefix(name, "Timestamp (") {
+				header["Timestamp"] = i
+			}
+		}
+		if _, ok := header[column]; ok {
+			columns = header
+		}
+	}
+	if columns == nil {
+		return nil, nil, fmt.Errorf("no header row with a %s column", column)
+	}
+
+	return rows, columns, nil
+}
+
+// valueColumn finds the glucose value column, which is named for the units of the export.
+func valueColumn(columns map[string]int, mgdl, mmol string) (int, string, error) {
+	if i, ok := columns[mgdl]; ok {
+		return i, "mgdl", nil
+	}
+	if i, ok := columns[mmol]; ok {
+		return i, "mmol", nil
+	}
+
+	return 0, "", fmt.Errorf("no %s or %s column", mgdl, mmol)
+}
+
+// field gets a field of a row, or an empty string when the row is short.
+func field(fields []string, i int) string {
+	if i >= len(fields) {
+		return ""
+	}
+
+	return strings.TrimSpace(fields[i])
+}
+
+// parseValue parses a glucose value in the units into mg/dl.
+func parseValue(value, units string) (int, error) {
+	switch strings.ToLower(value) {
+	case "low":
+		return minMgDl, nil
+	case "high":
+		return maxMgDl, nil
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid glucose value %q", value)
+	}
+	mgdl := glucose.MgDl(number, units)
+	if mgdl < minValid || mgdl > maxValid {
+		return 0, fmt.Errorf("glucose value %q out of range", value)
+	}
+
+	return int(math.Round(mgdl)), nil
+}
+
+// libreViewLayouts are the timestamp layouts LibreView uses for the different locales.
+var libreViewLayouts = []string{"01-02-2006 03:04 PM", "02-01-2006 15:04", "2006-01-02 15:04", "02/01/2006 15:04", "01/02/2006 03:04 PM"}
+
+// parseLibreViewTime parses a LibreView device timestamp.
+func parseLibreViewTime(value string, location *time.Location) (time.Time, error) {
+	for _, layout := range libreViewLayouts {
+		t, err := time.ParseInLocation(layout, value, location)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
+
+// Unstored gets the imported readings which aren't within DuplicateWindow of a stored reading, so
+// readings already fetched by the app or imported from another export aren't counted twice. Both
+// are oldest first.
+func Unstored(readings, stored []glucose.Reading) []glucose.Reading {
+	var unstored []glucose.Reading
+	next := 0
+	for _, reading := range readings {
+		for next < len(stored) && stored[next].Time.Before(reading.Time.Add(-DuplicateWindow)) {
+			next++
+		}
+		if next < len(stored) && stored[next].Time.Before(reading.Time.Add(DuplicateWindow)) {
+			continue
+		}
+		unstored = append(unstored, reading)
+	}
+
+	return unstored
+}
+
+// deduplicate sorts the readings, keeps the first reading of those within DuplicateWindow of each
+// other and calculates the deltas.
+func deduplicate(readings []glucose.Reading) []glucose.Reading {
+	slices.SortStableFunc(readings, func(a, b glucose.Reading) int {
+		return a.Time.Compare(b.Time)
+	})
+	var kept []glucose.Reading
+	for _, reading := range readings {
+		if len(kept) > 0 && reading.Time.Sub(kept[len(kept)-1].Time) < DuplicateWindow {
+			continue
+		}
+		kept = append(kept, reading)
+	}
+	readings = kept
+	for i := 1; i < len(readings); i++ {
+		readings[i].Delta = readings[i].MgDl - readings[i-1].MgDl
+	}
+
+	return readings
+}
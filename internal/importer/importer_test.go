@@ -0,0 +1,158 @@
+package importer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+)
+
+var location = time.FixedZone("AEST", 10*60*60)
+
+const clarity = `Index,Timestamp (YYYY-MM-DDThh:mm:ss),Event Type,Event Subtype,Patient Info,Device Info,Source Device ID,Glucose Value (mg/dL),Insulin Value (u),Carb Value (grams),Duration (hh:mm:ss),Glucose Rate of Change (mg/dL/min),Transmitter Time (Long Integer),Transmitter ID
+1,,FirstName,,Jane,,,,,,,,,
+2,,Device,,,G6,Android G6,,,,,,,
+3,2024-03-01T08:00:00,EGV,,,,Android G6,120,,,,1.5,1,8XXXXX
+4,2024-03-01T08:05:00,EGV,,,,Android G6,Low,,,,,2,8XXXXX
+5,2024-03-01T08:05:00,EGV,,,,Android G6,45,,,,,2,8XXXXX
+6,2024-03-01T08:10:00,Insulin,Fast-Acting,,,Android G6,,4,,,,,
+7,2024-03-01T08:15:00,EGV,,,,Android G6,abc,,,,,3,8XXXXX
+8,2024-03-01 08:20,EGV,,,,Android G6,110,,,,,4,8XXXXX
+`
+
+const libreView = `Glucose Data,Generated on,03-02-2024 09:00 UTC,Generated by,Jane
+Device,Serial Number,Device Timestamp,Record Type,Historic Glucose mmol/L,Scan Glucose mmol/L,Non-numeric Rapid-Acting Insulin,Rapid-Acting Insulin (units)
+FreeStyle LibreLink,ABC,01-03-2024 08:00,0,5.5,,,
+FreeStyle LibreLink,ABC,01-03-2024 08:07,1,,6.1,,
+FreeStyle LibreLink,ABC,01-03-2024 08:10,4,,,,2
+FreeStyle LibreLink,ABC,01-03-2024 08:15,0,99,,,
+`
+
+const nightscout = `[
+	{"type": "sgv", "sgv": 130, "date": 1709287200000, "direction": "FortyFiveUp"},
+	{"type": "mbg", "mbg": 128, "date": 1709287300000},
+	{"type": "sgv", "sgv": 125, "dateString": "2024-03-01T09:55:00.000Z", "direction": "Flat"},
+	{"type": "sgv", "sgv": 0, "date": 1709287800000}
+]`
+
+func TestDetect(t *testing.T) {
+	for want, data := range map[string]string{"clarity": clarity, "libreview": libreView, "nightscout": nightscout} {
+		got, err := Detect([]byte(data))
+		if err != nil || got != want {
+			t.Errorf("Detect() = %q, %v, want %q", got, err, want)
+		}
+	}
+	_, err := Detect([]byte("time,value\n"))
+	if err == nil {
+		t.Error("expected an unrecognised export error")
+	}
+}
+
+func TestClarity(t *testing.T) {
+	result, err := Parse("clarity", []byte(clarity), location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the duplicate reading at 08:05 is dropped, keeping the first
+	if len(result.Readings) != 2 {
+		t.Fatalf("readings = %+v", result.Readings)
+	}
+	first, second := result.Readings[0], result.Readings[1]
+	if !first.Time.Equal(time.Date(2024, 3, 1, 8, 0, 0, 0, location)) || first.MgDl != 120 || first.Rate != 1.5 || first.Source != "clarity" {
+		t.Errorf("first = %+v", first)
+	}
+	if second.MgDl != minMgDl || second.Delta != minMgDl-120 {
+		t.Errorf("second = %+v, want Low read as %d", second, minMgDl)
+	}
+	if result.Skipped != 3 {
+		t.Errorf("skipped = %d, want the metadata and insulin rows", result.Skipped)
+	}
+	if len(result.Errors) != 2 || result.Errors[0].Row != 8 || result.Errors[1].Row != 9 {
+		t.Errorf("errors = %v", result.Errors)
+	}
+}
+
+func TestLibreView(t *testing.T) {
+	result, err := Parse("libreview", []byte(libreView), location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Readings) != 2 || result.Readings[0].MgDl != 99 || result.Readings[1].MgDl != 110 {
+		t.Fatalf("readings = %+v", result.Readings)
+	}
+	if !result.Readings[1].Time.Equal(time.Date(2024, 3, 1, 8, 7, 0, 0, location)) {
+		t.Errorf("scan time = %s", result.Readings[1].Time)
+	}
+	if result.Skipped != 1 || len(result.Errors) != 1 || result.Errors[0].Row != 6 {
+		t.Errorf("skipped = %d, errors = %v", result.Skipped, result.Errors)
+	}
+}
+
+func TestNightscout(t *testing.T) {
+	result, err := Parse("nightscout", []byte(nightscout), location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Readings) != 2 {
+		t.Fatalf("readings = %+v", result.Readings)
+	}
+	if result.Readings[0].MgDl != 125 || result.Readings[0].Trend != "FLAT" {
+		t.Errorf("first = %+v", result.Readings[0])
+	}
+	if result.Readings[1].MgDl != 130 || result.Readings[1].Trend != "FORTY_FIVE_UP" || result.Readings[1].Delta != 5 {
+		t.Errorf("second = %+v", result.Readings[1])
+	}
+	if result.Skipped != 1 || len(result.Errors) != 1 || result.Errors[0].Row != 4 {
+		t.Errorf("skipped = %d, errors = %v", result.Skipped, result.Errors)
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	_, err := Parse("carelink", []byte(clarity), location)
+	if err == nil {
+		t.Error("expected an unknown format error")
+	}
+}
+
+func TestUnstoredOverlappingSources(t *testing.T) {
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, location)
+	// readings fetched from SugarMate have millisecond times
+	stored := []glucose.Reading{
+		{Time: start.Add(-4*time.Minute + 987*time.Millisecond), MgDl: 118, Source: glucose.Source},
+		{Time: start.Add(time.Minute + 12*time.Millisecond), MgDl: 121, Source: glucose.Source},
+		{Time: start.Add(6*time.Minute + 3*time.Millisecond), MgDl: 124, Source: glucose.Source},
+	}
+	clarity, err := Parse("clarity", []byte(`Index,Timestamp (YYYY-MM-DDThh:mm:ss),Event Type,Event Subtype,Patient Info,Device Info,Source Device ID,Glucose Value (mg/dL),Insulin Value (u),Carb Value (grams),Duration (hh:mm:ss),Glucose Rate of Change (mg/dL/min),Transmitter Time (Long Integer),Transmitter ID
+1,2024-03-01T07:55:01,EGV,,,,Android G6,118,,,,,1,8XXXXX
+2,2024-03-01T08:00:59,EGV,,,,Android G6,121,,,,,2,8XXXXX
+3,2024-03-01T08:06:00,EGV,,,,Android G6,124,,,,,3,8XXXXX
+4,2024-03-01T08:11:00,EGV,,,,Android G6,127,,,,,4,8XXXXX
+`), location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readings := Unstored(clarity.Readings, stored)
+	if len(readings) != 1 || readings[0].MgDl != 127 {
+		t.Fatalf("expected only the reading SugarMate doesn't have, got %+v", readings)
+	}
+
+	// LibreView times are to the minute
+	stored = append(stored, readings...)
+	libre, err := Parse("libreview", []byte(`Glucose Data,Generated on,03-02-2024 09:00 UTC,Generated by,Jane
+Device,Serial Number,Device Timestamp,Record Type,Historic Glucose mmol/L,Scan Glucose mmol/L,Non-numeric Rapid-Acting Insulin,Rapid-Acting Insulin (units)
+FreeStyle LibreLink,ABC,01-03-2024 08:12,0,7.0,,,
+FreeStyle LibreLink,ABC,01-03-2024 08:14,1,,7.2,,
+FreeStyle LibreLink,ABC,01-03-2024 08:17,0,7.3,,,
+`), location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the scan 2 minutes after the historic reading is the same reading
+	if len(libre.Readings) != 2 {
+		t.Fatalf("expected the scan to be dropped, got %+v", libre.Readings)
+	}
+	readings = Unstored(libre.Readings, stored)
+	if len(readings) != 1 || !readings[0].Time.Equal(start.Add(17*time.Minute)) {
+		t.Errorf("expected only the reading at 08:17, got %+v", readings)
+	}
+}
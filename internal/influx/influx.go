@@ -0,0 +1,158 @@
+package influx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+// Measurement is the measurement readings are written to.
+const Measurement = "glucose"
+
+// Backfill is how far back the stored history is written when nothing has been written yet, so
+// readings missed while the app wasn't running are filled in.
+const Backfill = 24 * time.Hour
+
+var (
+	client = &http.Client{Timeout: 10 * time.Second}
+	// written is the time of the newest reading written.
+	written time.Time
+	mutex   sync.Mutex
+)
+
+// tagEscaper escapes tag values, fieldEscaper escapes string field values.
+var (
+	tagEscaper   = strings.NewReplacer(`\`, `\\`, ",", `\,`, "=", `\=`, " ", `\ `)
+	fieldEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// Line formats the reading as a line of line protocol tagged with its source and the account.
+func Line(reading glucose.Reading, account string) string {
+	line := Measurement
+	// tags are sorted by key and left out when empty as line protocol doesn't allow empty tag values
+	if account != "" {
+		line += ",account=" + tagEscaper.Replace(account)
+	}
+	if reading.Source != "" {
+		line += ",source=" + tagEscaper.Replace(reading.Source)
+	}
+	fields := []string{
+		"mg_dl=" + strconv.Itoa(reading.MgDl) + "i",
+		"mmol=" + strconv.FormatFloat(math.Round(glucose.Value(float64(reading.MgDl), "mmol")*100)/100, 'f', -1, 64),
+		"delta=" + strconv.Itoa(reading.Delta) + "i",
+		"rate=" + strconv.FormatFloat(reading.Rate, 'f', -1, 64),
+	}
+	if reading.Trend != "" {
+		fields = append(fields, `trend="`+fieldEscaper.Replace(reading.Trend)+`"`)
+	}
+
+	return fmt.Sprintf("%s %s %d", line, strings.Join(fields, ","), reading.Time.UnixNano())
+}
+
+// Write writes the lines to the server and the file of the settings.
+func Write(setting settings.Influx, lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	body := []byte(strings.Join(lines, "\n") + "\n")
+	var errs []error
+	if setting.URL != "" {
+		errs = append(errs, post(setting, body))
+	}
+	if setting.File != "" {
+		errs = append(errs, appendFile(setting.File, body))
+	}
+
+	return errors.Join(errs...)
+}
+
+// post writes the body to the v2 write API of the server.
+func post(setting settings.Influx, body []byte) error {
+	query := url.Values{}
+	query.Set("org", setting.Org)
+	query.Set("bucket", setting.Bucket)
+	query.Set("precision", "ns")
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(setting.URL, "/")+"/api/v2/write?"+query.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if setting.Token != "" {
+		req.Header.Set("Authorization", "Token "+setting.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("influxdb %s responded %s: %s", setting.URL, resp.Status, bytes.TrimSpace(message))
+	}
+
+	return nil
+}
+
+// appendFile appends the body to the file.
+func appendFile(name string, body []byte) error {
+	file, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(body)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// PublishReading writes the reading along with the stored history since the last reading written,
+// history gets the stored readings between two times.
+func PublishReading(reading glucose.Reading, setting settings.Setting, account string, history func(from, to time.Time) ([]glucose.Reading, error)) {
+	if setting.Influx.Enabled != "true" {
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	from := reading.Time.Add(-Backfill)
+	if written.After(from) {
+		from = written.Add(time.Nanosecond)
+	}
+	readings, err := history(from, reading.Time)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+	}
+	// the latest reading replaces its stored copy as it holds the calculated rate
+	readings = slices.DeleteFunc(readings, func(stored glucose.Reading) bool {
+		return stored.Missing() || stored.Time.Equal(reading.Time)
+	})
+	readings = append(readings, reading)
+	var lines []string
+	for _, r := range readings {
+		lines = append(lines, Line(r, account))
+	}
+	err = Write(setting.Influx, lines)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		return
+	}
+	written = reading.Time
+}
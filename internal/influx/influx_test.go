@@ -0,0 +1,76 @@
+package influx
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+func TestLine(t *testing.T) {
+	reading := glucose.Reading{Time: time.Unix(1709280000, 0), MgDl: 126, Trend: "FLAT", Delta: -4, Rate: 0.5, Source: "sugarmate"}
+	want := `glucose,account=jane\ doe@example.com,source=sugarmate mg_dl=126i,mmol=6.99,delta=-4i,rate=0.5,trend="FLAT" 1709280000000000000`
+	if got := Line(reading, "jane doe@example.com"); got != want {
+		t.Errorf("Line() =\n%s\nwant\n%s", got, want)
+	}
+	reading.Source, reading.Trend = "", ""
+	if got := Line(reading, ""); !strings.HasPrefix(got, "glucose mg_dl=126i,") || strings.Contains(got, "trend") {
+		t.Errorf("Line() = %s, want empty tags and trend left out", got)
+	}
+}
+
+func TestPublishReading(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v2/write" || req.URL.Query().Get("bucket") != "cgm" || req.Header.Get("Authorization") != "Token secret" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		body, _ := io.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	file := filepath.Join(t.TempDir(), "readings.lp")
+	setting := settings.Setting{Influx: settings.Influx{Enabled: "true", URL: server.URL, Org: "home", Bucket: "cgm", Token: "secret", File: file}}
+
+	now := time.Unix(1709280000, 0)
+	stored := []glucose.Reading{
+		{Time: now.Add(-10 * time.Minute), MgDl: 110},
+		{Time: now.Add(-5 * time.Minute), MgDl: 115},
+		{Time: now, MgDl: 120},
+	}
+	history := func(from, to time.Time) ([]glucose.Reading, error) {
+		var readings []glucose.Reading
+		for _, reading := range stored {
+			if !reading.Time.Before(from) && !reading.Time.After(to) {
+				readings = append(readings, reading)
+			}
+		}
+		return readings, nil
+	}
+	PublishReading(glucose.Reading{Time: now, MgDl: 120, Rate: 1}, setting, "", history)
+	stored = append(stored, glucose.Reading{Time: now.Add(5 * time.Minute), MgDl: 125})
+	PublishReading(glucose.Reading{Time: now.Add(5 * time.Minute), MgDl: 125}, setting, "", history)
+
+	if len(bodies) != 2 {
+		t.Fatalf("bodies = %q", bodies)
+	}
+	if strings.Count(bodies[0], "\n") != 3 || !strings.Contains(bodies[0], "mg_dl=120i,mmol=6.66,delta=0i,rate=1 ") {
+		t.Errorf("first write backfills the history and the latest reading, got\n%s", bodies[0])
+	}
+	if strings.Count(bodies[1], "\n") != 1 || !strings.Contains(bodies[1], "mg_dl=125i") {
+		t.Errorf("second write only holds the new reading, got\n%s", bodies[1])
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || string(data) != bodies[0]+bodies[1] {
+		t.Errorf("file = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+)
+
+// ContentType is the content type of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Trends are the trends exposed by the trend enum gauge.
+var Trends = []string{"DOUBLE_UP", "SINGLE_UP", "FORTY_FIVE_UP", "FLAT", "FORTY_FIVE_DOWN", "SINGLE_DOWN", "DOUBLE_DOWN", "NOT_COMPUTABLE"}
+
+// Buckets are the upper bounds in seconds of the API request latency histogram buckets.
+var Buckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram counts observations into the buckets.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+var (
+	mutex    sync.Mutex
+	reading  glucose.Reading
+	requests = map[string]*histogram{}
+	failures = map[string]uint64{}
+	auths    = map[[2]string]uint64{}
+	alerts   = map[string]uint64{}
+)
+
+// SetReading records the latest reading.
+func SetReading(latest glucose.Reading) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if latest.Missing() {
+		return
+	}
+	reading = latest
+}
+
+// ObserveRequest records the latency of a request to a SugarMate API endpoint and whether it failed.
+func ObserveRequest(endpoint string, duration time.Duration, failed bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	h, ok := requests[endpoint]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(Buckets))}
+		requests[endpoint] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range Buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+	if failed {
+		failures[endpoint]++
+	}
+}
+
+// Auth records an authentication with SugarMate, kind is login or refresh.
+func Auth(kind string, success bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	auths[[2]string{kind, result}]++
+}
+
+// Listen counts the alerts fired by each rule.
+func Listen(events <-chan alert.Event) {
+	for event := range events {
+		mutex.Lock()
+		alerts[event.Rule]++
+		mutex.Unlock()
+	}
+}
+
+// Handler serves the metrics in the Prometheus text exposition format.
+func Handler(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", ContentType)
+	Write(w, time.Now())
+}
+
+// Write writes the metrics in the Prometheus text exposition format, the reading metrics are left
+// out until there is a reading so a missing series can be alerted on.
+func Write(w io.Writer, now time.Time) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if !reading.Time.IsZero() {
+		gauge(w, "sugarmate_glucose_mgdl", "Latest glucose reading in mg/dL.", float64(reading.MgDl))
+		gauge(w, "sugarmate_glucose_mmol", "Latest glucose reading in mmol/L.", math.Round(glucose.Value(float64(reading.MgDl), "mmol")*100)/100)
+		header(w, "sugarmate_trend", "gauge", "Trend of the latest reading, 1 for the current trend.")
+		for _, trend := range Trends {
+			value := 0
+			if reading.Trend == trend {
+				value = 1
+			}
+			fmt.Fprintf(w, "sugarmate_trend{trend=%q} %d\n", trend, value)
+		}
+		gauge(w, "sugarmate_rate_mgdl_per_minute", "Rate of change of the latest reading in mg/dL per minute.", reading.Rate)
+		gauge(w, "sugarmate_reading_timestamp_seconds", "Unix time of the latest reading.", float64(reading.Time.Unix()))
+		gauge(w, "sugarmate_reading_age_seconds", "Seconds since the latest reading was taken.", now.Sub(reading.Time).Seconds())
+	}
+
+	header(w, "sugarmate_api_request_duration_seconds", "histogram", "Latency of requests to the SugarMate API by endpoint.")
+	for _, endpoint := range slices.Sorted(maps.Keys(requests)) {
+		h := requests[endpoint]
+		for i, bound := range Buckets {
+			fmt.Fprintf(w, "sugarmate_api_request_duration_seconds_bucket{endpoint=%q,le=\"%g\"} %d\n", endpoint, bound, h.counts[i])
+		}
+		fmt.Fprintf(w, "sugarmate_api_request_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", endpoint, h.count)
+		fmt.Fprintf(w, "sugarmate_api_request_duration_seconds_sum{endpoint=%q} %g\n", endpoint, h.sum)
+		fmt.Fprintf(w, "sugarmate_api_request_duration_seconds_count{endpoint=%q} %d\n", endpoint, h.count)
+	}
+	header(w, "sugarmate_api_request_errors_total", "counter", "Failed requests to the SugarMate API by endpoint.")
+	for _, endpoint := range slices.Sorted(maps.Keys(failures)) {
+		fmt.Fprintf(w, "sugarmate_api_request_errors_total{endpoint=%q} %d\n", endpoint, failures[endpoint])
+	}
+	header(w, "sugarmate_auth_total", "counter", "Authentications with SugarMate by type and result.")
+	for _, key := range slices.SortedFunc(maps.Keys(auths), func(a, b [2]string) int {
+		return strings.Compare(a[0]+a[1], b[0]+b[1])
+	}) {
+		fmt.Fprintf(w, "sugarmate_auth_total{type=%q,result=%q} %d\n", key[0], key[1], auths[key])
+	}
+	header(w, "sugarmate_alerts_fired_total", "counter", "Alerts fired by rule.")
+	for _, rule := range slices.Sorted(maps.Keys(alerts)) {
+		fmt.Fprintf(w, "sugarmate_alerts_fired_total{rule=%q} %d\n", rule, alerts[rule])
+	}
+}
+
+// header writes the help and type lines of a metric.
+func header(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// gauge writes a gauge with a single value.
+func gauge(w io.Writer, name, help string, value float64) {
+	header(w, name, "gauge", help)
+	fmt.Fprintf(w, "%s %g\n", name, value)
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+)
+
+func TestWrite(t *testing.T) {
+	now := time.Date(2024, 3, 1, 8, 10, 0, 0, time.UTC)
+	buf := new(bytes.Buffer)
+	Write(buf, now)
+	if strings.Contains(buf.String(), "sugarmate_reading_age_seconds") {
+		t.Error("reading metrics written before there was a reading")
+	}
+
+	SetReading(glucose.Reading{Time: now.Add(-5 * time.Minute), MgDl: 180, Trend: "SINGLE_UP", Rate: 2})
+	SetReading(glucose.Reading{Time: now})
+	ObserveRequest("events", 300*time.Millisecond, false)
+	ObserveRequest("events", 3*time.Second, true)
+	Auth("refresh", true)
+	events := make(chan alert.Event, 2)
+	events <- alert.Event{Rule: "high"}
+	events <- alert.Event{Rule: "high"}
+	close(events)
+	Listen(events)
+
+	buf.Reset()
+	Write(buf, now)
+	for _, want := range []string{
+		"sugarmate_glucose_mgdl 180\n",
+		"sugarmate_glucose_mmol 9.99\n",
+		`sugarmate_trend{trend="SINGLE_UP"} 1` + "\n",
+		`sugarmate_trend{trend="FLAT"} 0` + "\n",
+		"sugarmate_rate_mgdl_per_minute 2\n",
+		"sugarmate_reading_age_seconds 300\n",
+		`sugarmate_api_request_duration_seconds_bucket{endpoint="events",le="0.25"} 0` + "\n",
+		`sugarmate_api_request_duration_seconds_bucket{endpoint="events",le="0.5"} 1` + "\n",
+		`sugarmate_api_request_duration_seconds_bucket{endpoint="events",le="+Inf"} 2` + "\n",
+		`sugarmate_api_request_duration_seconds_sum{endpoint="events"} 3.3` + "\n",
+		`sugarmate_api_request_errors_total{endpoint="events"} 1` + "\n",
+		`sugarmate_auth_total{type="refresh",result="success"} 1` + "\n",
+		`sugarmate_alerts_fired_total{rule="high"} 2` + "\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics missing %q in\n%s", want, buf)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/payload"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// discoveryPrefix is the topic prefix Home Assistant listens for discovery config on.
+const discoveryPrefix = "homeassistant"
+
+var (
+	client paho.Client
+	config settings.MQTT
+	units  string
+	// state holds the last message published to each topic, it is published again on connecting
+	// because messages published while disconnected are dropped.
+	state   = map[string]Message{}
+	mutex   sync.Mutex
+	timeout = 10 * time.Second
+)
+
+// Message is a payload published to a topic, retained messages are the current state of the topic.
+type Message struct {
+	Topic    string
+	Payload  []byte
+	Retained bool
+}
+
+// sensor is the Home Assistant discovery config of a sensor.
+type sensor struct {
+	Name                string `json:"name"`
+	UniqueID            string `json:"unique_id"`
+	StateTopic          string `json:"state_topic"`
+	ValueTemplate       string `json:"value_template"`
+	UnitOfMeasurement   string `json:"unit_of_measurement,omitempty"`
+	StateClass          string `json:"state_class,omitempty"`
+	Icon                string `json:"icon"`
+	JSONAttributesTopic string `json:"json_attributes_topic"`
+	AvailabilityTopic   string `json:"availability_topic"`
+	Device              device `json:"device"`
+}
+
+type device struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+}
+
+// Configure connects to the broker, reconnecting when the settings have changed and
+// disconnecting when MQTT is disabled. Connecting is retried in the background.
+func Configure(setting settings.Setting) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if client != nil && setting.MQTT == config && setting.Units == units {
+		return
+	}
+	if client != nil {
+		client.Publish(Topic(config, "status"), 1, true, "offline").WaitTimeout(timeout)
+		client.Disconnect(250)
+		client = nil
+	}
+	config = setting.MQTT
+	units = setting.Units
+	if config.Enabled != "true" || config.Broker == "" {
+		return
+	}
+	hostname, _ := os.Hostname()
+	options := paho.NewClientOptions().
+		AddBroker(config.Broker).
+		SetClientID("SugarMateReader-"+hostname).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetWill(Topic(config, "status"), "offline", 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetOnConnectHandler(func(c paho.Client) {
+			publish(c, Message{Topic: Topic(setting.MQTT, "status"), Payload: []byte("online"), Retained: true})
+			for _, message := range Discovery(setting) {
+				publish(c, message)
+			}
+			mutex.Lock()
+			defer mutex.Unlock()
+			for topic, message := range state {
+				if strings.HasPrefix(topic, prefix(setting.MQTT)+"/") {
+					publish(c, message)
+				}
+			}
+		})
+	client = paho.NewClient(options)
+	client.Connect()
+}
+
+// PublishReading publishes the reading as the current state of the reading topic.
+func PublishReading(reading glucose.Reading, setting settings.Setting) {
+	send(Topic(setting.MQTT, "reading"), payload.Reading(reading, setting))
+}
+
+// Listen publishes every alert event as the current state of the alert topic.
+func Listen(events <-chan alert.Event, setting func() settings.Setting) {
+	for event := range events {
+		current := setting()
+		send(Topic(current.MQTT, "alert"), payload.Alert(event, current))
+	}
+}
+
+// Topic gets the topic below the configured prefix.
+func Topic(setting settings.MQTT, name string) string {
+	return prefix(setting) + "/" + name
+}
+
+// prefix gets the configured topic prefix.
+func prefix(setting settings.MQTT) string {
+	prefix := strings.Trim(setting.Topic, "/")
+	if prefix == "" {
+		return "sugarmatereader"
+	}
+
+	return prefix
+}
+
+// Discovery gets the Home Assistant discovery config of the glucose and trend sensors. Empty
+// config is published when discovery is disabled so Home Assistant removes the sensors.
+func Discovery(setting settings.Setting) []Message {
+	id := strings.ReplaceAll(prefix(setting.MQTT), "/", "_")
+	sensors := map[string]sensor{
+		"glucose": {
+			Name:              "Glucose",
+			ValueTemplate:     "{{ value_json.value }}",
+			UnitOfMeasurement: glucose.UnitLabel(setting.Units),
+			StateClass:        "measurement",
+			Icon:              "mdi:diabetes",
+		},
+		"trend": {
+			Name:          "Glucose trend",
+			ValueTemplate: "{{ value_json.trend }}",
+			Icon:          "mdi:trending-up",
+		},
+	}
+
+	var messages []Message
+	for _, name := range []string{"glucose", "trend"} {
+		message := Message{
+			Topic:    discoveryPrefix + "/sensor/" + id + "/" + name + "/config",
+			Payload:  []byte{},
+			Retained: true,
+		}
+		if setting.MQTT.Discovery == "true" {
+			entity := sensors[name]
+			entity.UniqueID = id + "_" + name
+			entity.StateTopic = Topic(setting.MQTT, "reading")
+			entity.JSONAttributesTopic = entity.StateTopic
+			entity.AvailabilityTopic = Topic(setting.MQTT, "status")
+			entity.Device = device{Identifiers: []string{id}, Name: "SugarMateReader"}
+			payload, err := json.Marshal(entity)
+			if err != nil {
+				log.Println("error:")
+				log.Println(err)
+				continue
+			}
+			message.Payload = payload
+		}
+		messages = append(messages, message)
+	}
+
+	return messages
+}
+
+// send publishes the payload as the current state of the topic when connected to a broker.
+func send(topic string, content payload.Payload) {
+	body, err := json.Marshal(content)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		return
+	}
+	message := Message{Topic: topic, Payload: body, Retained: true}
+	mutex.Lock()
+	defer mutex.Unlock()
+	state[topic] = message
+	if client != nil && client.IsConnectionOpen() {
+		publish(client, message)
+	}
+}
+
+// publish publishes the message without waiting for the broker to receive it.
+func publish(c paho.Client, message Message) {
+	token := c.Publish(message.Topic, 1, message.Retained, message.Payload)
+	go func() {
+		if !token.WaitTimeout(timeout) {
+			log.Println("error:")
+			log.Println("Timed out publishing to " + message.Topic)
+			return
+		}
+		if err := token.Error(); err != nil {
+			log.Println("error:")
+			log.Println(err)
+		}
+	}()
+}
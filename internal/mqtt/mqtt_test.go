@@ -0,0 +1,95 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+func TestTopic(t *testing.T) {
+	tests := map[string]string{
+		"":            "sugarmatereader/reading",
+		"home/sugar/": "home/sugar/reading",
+		"/glucose":    "glucose/reading",
+	}
+	for prefix, want := range tests {
+		if got := Topic(settings.MQTT{Topic: prefix}, "reading"); got != want {
+			t.Errorf("Topic(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	setting := settings.Setting{
+		Units: "mgdl",
+		MQTT:  settings.MQTT{Topic: "home/sugar", Discovery: "true"},
+	}
+	messages := Discovery(setting)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	message := messages[0]
+	if message.Topic != "homeassistant/sensor/home_sugar/glucose/config" || !message.Retained {
+		t.Errorf("message = %+v", message)
+	}
+	var config sensor
+	err := json.Unmarshal(message.Payload, &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.UniqueID != "home_sugar_glucose" || config.StateTopic != "home/sugar/reading" ||
+		config.AvailabilityTopic != "home/sugar/status" || config.UnitOfMeasurement != "mg/dL" {
+		t.Errorf("config = %+v", config)
+	}
+}
+
+func TestDiscoveryDisabledRemovesSensors(t *testing.T) {
+	for _, message := range Discovery(settings.Setting{}) {
+		if len(message.Payload) != 0 || !message.Retained {
+			t.Errorf("message = %+v, want an empty retained payload", message)
+		}
+	}
+}
+
+// TestPublishReading needs a broker, e.g. MQTT_TEST_BROKER=tcp://localhost:1883 with mosquitto running.
+func TestPublishReading(t *testing.T) {
+	broker := os.Getenv("MQTT_TEST_BROKER")
+	if broker == "" {
+		t.Skip("MQTT_TEST_BROKER is not set")
+	}
+	setting := settings.Setting{
+		Units: "mmol",
+		MQTT:  settings.MQTT{Enabled: "true", Broker: broker, Topic: "sugarmatereader-test"},
+	}
+	Configure(setting)
+	defer Configure(settings.Setting{})
+	PublishReading(glucose.Reading{Time: time.Now(), MgDl: 90, Trend: "FLAT"}, setting)
+
+	// the reading is retained so a client subscribing afterwards still receives it
+	received := make(chan []byte, 1)
+	subscriber := paho.NewClient(paho.NewClientOptions().AddBroker(broker).SetClientID("SugarMateReader-test"))
+	if token := subscriber.Connect(); token.WaitTimeout(timeout) && token.Error() != nil {
+		t.Fatal(token.Error())
+	}
+	defer subscriber.Disconnect(250)
+	time.Sleep(time.Second)
+	subscriber.Subscribe(Topic(setting.MQTT, "reading"), 1, func(c paho.Client, message paho.Message) {
+		received <- message.Payload()
+	})
+
+	select {
+	case payload := <-received:
+		var reading map[string]any
+		json.Unmarshal(payload, &reading)
+		if reading["value"] != 5.0 || reading["unit"] != "mmol/L" {
+			t.Errorf("reading = %s", payload)
+		}
+	case <-time.After(timeout):
+		t.Fatal("reading was not published")
+	}
+}
@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/directory"
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	notificationsInterface = "org.freedesktop.Notifications"
+	acknowledgeAction      = "acknowledge"
+	snoozeActionPrefix     = "snooze_"
+)
+
+// actionNotifier sends desktop notifications with acknowledge and snooze actions.
+type actionNotifier struct {
+	conn   *dbus.Conn
+	engine *alert.Engine
+	ids    map[uint32]string
+	mutex  sync.Mutex
+}
+
+// newActionNotifier connects to the notification server and listens for actions being invoked.
+func newActionNotifier(engine *alert.Engine) (*actionNotifier, error) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, err
+	}
+	err = conn.AddMatchSignal(dbus.WithMatchInterface(notificationsInterface))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	notifier := &actionNotifier{
+		conn:   conn,
+		engine: engine,
+		ids:    map[uint32]string{},
+	}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	go notifier.listen(signals)
+
+	return notifier, nil
+}
+
+// notify sends a notification for the alert event, critical notifications stay until dismissed.
+func (n *actionNotifier) notify(event alert.Event, critical bool) error {
+	actions := []string{acknowledgeAction, "Acknowledge"}
+	for _, duration := range alert.SnoozeDurations {
+		minutes := int(duration.Minutes())
+		actions = append(actions, snoozeActionPrefix+strconv.Itoa(minutes), fmt.Sprintf("Snooze %d min", minutes))
+	}
+	hints := map[string]dbus.Variant{}
+	timeout := int32(-1)
+	if critical {
+		hints["urgency"] = dbus.MakeVariant(byte(2))
+		timeout = 0
+	}
+	var id uint32
+	err := n.conn.Object(notificationsInterface, "/org/freedesktop/Notifications").Call(
+		notificationsInterface+".Notify",
+		0,
+		"SugarMateReader",
+		uint32(0),
+		directory.ConfigDir+"warning.png",
+		event.Title,
+		event.Message,
+		actions,
+		hints,
+		timeout,
+	).Store(&id)
+	if err != nil {
+		return err
+	}
+	n.mutex.Lock()
+	n.ids[id] = event.Rule
+	n.mutex.Unlock()
+
+	return nil
+}
+
+// listen handles actions invoked on the notifications which were sent.
+func (n *actionNotifier) listen(signals chan *dbus.Signal) {
+	for signal := range signals {
+		if len(signal.Body) < 2 {
+			continue
+		}
+		id, ok := signal.Body[0].(uint32)
+		if !ok {
+			continue
+		}
+		n.mutex.Lock()
+		rule, ok := n.ids[id]
+		if signal.Name == notificationsInterface+".NotificationClosed" {
+			delete(n.ids, id)
+		}
+		n.mutex.Unlock()
+		if !ok || signal.Name != notificationsInterface+".ActionInvoked" {
+			continue
+		}
+		action, _ := signal.Body[1].(string)
+		switch {
+		case action == acknowledgeAction:
+			n.engine.Acknowledge(rule)
+		case strings.HasPrefix(action, snoozeActionPrefix):
+			minutes, err := strconv.Atoi(strings.TrimPrefix(action, snoozeActionPrefix))
+			if err == nil {
+				n.engine.Snooze(rule, time.Duration(minutes)*time.Minute)
+			}
+		}
+	}
+}
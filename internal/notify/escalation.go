@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"log"
+	"os/exec"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/directory"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	"github.com/gen2brain/beeep"
+)
+
+var (
+	// escalating holds when the alert each running chain is for started.
+	escalating      = map[string]time.Time{}
+	escalatingMutex sync.Mutex
+	// players are tried in order to play the alarm sound.
+	players = [][]string{{"paplay"}, {"pw-play"}, {"aplay", "-q"}, {"afplay"}}
+)
+
+// escalate sounds an alarm and then sends a critical notification for as long as the alert stays
+// active, unacknowledged and unsnoozed. Only one chain runs for each alert, it stops when the alert
+// clears and fires again as the new alert starts its own chain.
+func escalate(engine *alert.Engine, actions *actionNotifier, event alert.Event, config settings.Alert) {
+	since := engine.State(event.Rule).Since
+	escalatingMutex.Lock()
+	if running, ok := escalating[event.Rule]; ok && running.Equal(since) {
+		escalatingMutex.Unlock()
+		return
+	}
+	escalating[event.Rule] = since
+	escalatingMutex.Unlock()
+	defer func() {
+		escalatingMutex.Lock()
+		if escalating[event.Rule].Equal(since) {
+			delete(escalating, event.Rule)
+		}
+		escalatingMutex.Unlock()
+	}()
+
+	steps := []struct {
+		delay time.Duration
+		run   func()
+	}{
+		{settings.Minutes(config.EscalateSound), playAlarm},
+		{settings.Minutes(config.EscalateCritical), func() {
+			if actions != nil {
+				err := actions.notify(event, true)
+				if err == nil {
+					return
+				}
+				log.Println("error:")
+				log.Println(err)
+			}
+			beeep.Alert(event.Title, event.Message, directory.ConfigDir+"warning.png")
+		}},
+	}
+	start := time.Now()
+	for _, step := range steps {
+		time.Sleep(time.Until(start.Add(step.delay)))
+		state := engine.State(event.Rule)
+		if !state.Active || !state.Since.Equal(since) || state.Acknowledged || time.Now().Before(state.SnoozedUntil) {
+			return
+		}
+		step.run()
+	}
+}
+
+// playAlarm plays the bundled alarm sound, falling back to a beep when no player is installed.
+func playAlarm() {
+	for _, player := range players {
+		path, err := exec.LookPath(player[0])
+		if err != nil {
+			continue
+		}
+		args := slices.Concat(player[1:], []string{directory.ConfigDir + "alarm.wav"})
+		err = exec.Command(path, args...).Run()
+		if err == nil {
+			return
+		}
+		log.Println("error:")
+		log.Println(err)
+	}
+	beeep.Beep(beeep.DefaultFreq, beeep.DefaultDuration)
+}
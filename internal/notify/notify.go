@@ -1,52 +1,40 @@
 package notify
 
 import (
-	"strconv"
+	"log"
 
+	"github.com/brettcodling/SugarMateReader/internal/alert"
 	"github.com/brettcodling/SugarMateReader/internal/directory"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
 	"github.com/gen2brain/beeep"
 )
 
-var (
-	highLastValue bool
-)
-
 // Warning creates a warning notification.
 func Warning(title, context string) {
 	beeep.Notify(title, context, directory.ConfigDir+"warning.png")
 }
 
-func AlertLow(enabled bool, floatValue float64, lowLevel string) error {
-	if enabled {
-		lowAlertLevel, err := strconv.ParseFloat(lowLevel, 64)
-		if err != nil {
-			return err
-		}
-		if lowAlertLevel > 0 && floatValue <= lowAlertLevel {
-			Warning("ALERT!", "LOW GLUCOSE")
-		}
+// Listen creates a notification for every alert event emitted by the engine, the notifications
+// allow the alert to be acknowledged or snoozed when the notification server supports actions.
+// Escalating alerts are escalated when enabled in the alert settings of the active profile.
+func Listen(engine *alert.Engine, events <-chan alert.Event, alertSettings func() settings.Alert) {
+	actions, err := newActionNotifier(engine)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
 	}
-
-	return nil
-}
-
-func AlertHigh(enabled bool, floatValue float64, highLevel string) error {
-	if enabled {
-		if !highLastValue {
-			highAlertLevel, err := strconv.ParseFloat(highLevel, 64)
-			if err != nil {
-				return err
-			}
-			if highAlertLevel > 0 && floatValue >= highAlertLevel {
-				highLastValue = true
-				Warning("ALERT!", "HIGH GLUCOSE")
-			} else {
-				highLastValue = false
+	for event := range events {
+		if config := alertSettings(); event.Escalates && config.Escalate == "true" {
+			go escalate(engine, actions, event, config)
+		}
+		if actions != nil {
+			err := actions.notify(event, false)
+			if err == nil {
+				continue
 			}
+			log.Println("error:")
+			log.Println(err)
 		}
-	} else {
-		highLastValue = false
+		Warning(event.Title, event.Message)
 	}
-
-	return nil
 }
@@ -0,0 +1,46 @@
+package payload
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+// Payload is the JSON body of a reading or alert, posted to webhooks and published over MQTT.
+type Payload struct {
+	Type      string   `json:"type"`
+	Rule      string   `json:"rule,omitempty"`
+	Message   string   `json:"message,omitempty"`
+	Value     float64  `json:"value"`
+	Unit      string   `json:"unit"`
+	Trend     string   `json:"trend"`
+	Threshold *float64 `json:"threshold,omitempty"`
+	Timestamp string   `json:"timestamp"`
+}
+
+// Reading gets the payload for a reading.
+func Reading(reading glucose.Reading, setting settings.Setting) Payload {
+	return Payload{
+		Type:      "reading",
+		Value:     glucose.Round(float64(reading.MgDl), setting.Units),
+		Unit:      glucose.UnitLabel(setting.Units),
+		Trend:     reading.Trend,
+		Timestamp: reading.Time.UTC().Format(time.RFC3339),
+	}
+}
+
+// Alert gets the payload for an alert event.
+func Alert(event alert.Event, setting settings.Setting) Payload {
+	payload := Reading(event.Reading, setting)
+	payload.Type = "alert"
+	payload.Rule = event.Rule
+	payload.Message = event.Message
+	if threshold, err := strconv.ParseFloat(event.Threshold, 64); err == nil {
+		payload.Threshold = &threshold
+	}
+
+	return payload
+}
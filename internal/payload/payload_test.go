@@ -0,0 +1,42 @@
+package payload
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+func TestAlert(t *testing.T) {
+	event := alert.Event{
+		Rule:      "low",
+		Message:   "Low: 3.5",
+		Threshold: "4.0",
+		Reading: glucose.Reading{
+			Time:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.FixedZone("AEST", 10*60*60)),
+			MgDl:  63,
+			Trend: "FALLING",
+		},
+	}
+	payload := Alert(event, settings.Setting{Units: "mmol"})
+	if payload.Type != "alert" || payload.Rule != "low" || payload.Message != "Low: 3.5" || payload.Value != 3.5 ||
+		payload.Unit != "mmol/L" || payload.Trend != "FALLING" || payload.Timestamp != "2024-01-01T17:04:05Z" {
+		t.Errorf("payload = %+v", payload)
+	}
+	if payload.Threshold == nil || *payload.Threshold != 4 {
+		t.Errorf("threshold = %v, want 4", payload.Threshold)
+	}
+	event.Threshold = ""
+	if payload := Alert(event, settings.Setting{Units: "mmol"}); payload.Threshold != nil {
+		t.Errorf("threshold = %v, want none", *payload.Threshold)
+	}
+}
+
+func TestReading(t *testing.T) {
+	payload := Reading(glucose.Reading{Time: time.Now(), MgDl: 100, Trend: "FLAT"}, settings.Setting{Units: "mgdl"})
+	if payload.Type != "reading" || payload.Value != 100 || payload.Unit != "mg/dL" || payload.Rule != "" {
+		t.Errorf("payload = %+v", payload)
+	}
+}
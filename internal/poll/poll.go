@@ -0,0 +1,92 @@
+package poll
+
+import (
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+const (
+	// DefaultCadence is the time between readings when it can't be detected, 5 minutes like the
+	// Dexcom G6 and Libre 2.
+	DefaultCadence = 5 * time.Minute
+	// DefaultDelay gives SugarMate time to receive a reading before it's fetched.
+	DefaultDelay = 10 * time.Second
+	// DefaultRetry is how often a late reading is fetched again.
+	DefaultRetry = 30 * time.Second
+)
+
+// Cadence gets the time between readings, detected from the readings when it's set to auto.
+func Cadence(setting settings.Poll, readings []glucose.Reading) time.Duration {
+	minutes, err := strconv.Atoi(setting.Cadence)
+	if err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+
+	return Detect(readings)
+}
+
+// Detect gets the time between readings from the most common gap between them, rounded to a whole
+// minute, or DefaultCadence when there aren't enough readings.
+func Detect(readings []glucose.Reading) time.Duration {
+	readings = slices.DeleteFunc(slices.Clone(readings), glucose.Reading.Missing)
+	slices.SortFunc(readings, func(a, b glucose.Reading) int {
+		return a.Time.Compare(b.Time)
+	})
+	counts := map[time.Duration]int{}
+	for i := 1; i < len(readings); i++ {
+		gap := readings[i].Time.Sub(readings[i-1].Time).Round(time.Minute)
+		if gap > 0 {
+			counts[gap]++
+		}
+	}
+	cadence, most := DefaultCadence, 0
+	for gap, count := range counts {
+		// ties go to the shorter gap, missed readings only ever make gaps longer
+		if count > most || count == most && gap < cadence {
+			cadence, most = gap, count
+		}
+	}
+	if most < 2 {
+		return DefaultCadence
+	}
+
+	return cadence
+}
+
+// Seconds parses a setting holding a number of seconds, using the default when it isn't set.
+func Seconds(value string, fallback time.Duration) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return fallback
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// Next gets when to fetch the next reading, given the time of the latest reading. Fetches are
+// aligned to delay after each expected reading. When the expected reading is late it's fetched
+// every retry until the reading after it is due, then fetches go back to the expected times so a
+// sensor which stops sending isn't fetched constantly. A retry of 0 turns off retries.
+func Next(last, now time.Time, cadence, delay, retry time.Duration) time.Time {
+	if last.IsZero() || cadence <= 0 {
+		return now.Add(DefaultCadence)
+	}
+	expected := last.Add(cadence).Add(delay)
+	if now.Before(expected) {
+		return expected
+	}
+	if retry > 0 && now.Before(expected.Add(cadence)) {
+		if now.Add(retry).Before(expected.Add(cadence)) {
+			return now.Add(retry)
+		}
+
+		return expected.Add(cadence)
+	}
+	missed := now.Sub(expected)/cadence + 1
+
+	return expected.Add(missed * cadence)
+}
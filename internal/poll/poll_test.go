@@ -0,0 +1,85 @@
+package poll
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+func readings(start time.Time, gaps ...time.Duration) []glucose.Reading {
+	readings := []glucose.Reading{{Time: start, MgDl: 100}}
+	for _, gap := range gaps {
+		start = start.Add(gap)
+		readings = append(readings, glucose.Reading{Time: start, MgDl: 100})
+	}
+
+	return readings
+}
+
+func TestDetect(t *testing.T) {
+	start := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	minute := time.Minute
+	tests := []struct {
+		name     string
+		readings []glucose.Reading
+		want     time.Duration
+	}{
+		{"five minutes", readings(start, 5*minute, 5*minute, 10*minute, 5*minute), 5 * minute},
+		{"one minute", readings(start, minute, minute, 2*minute, minute, minute), minute},
+		{"jitter", readings(start, 5*minute+4*time.Second, 4*minute+55*time.Second, 5*minute), 5 * minute},
+		{"not enough readings", readings(start, minute), DefaultCadence},
+		{"none", nil, DefaultCadence},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.readings); got != tt.want {
+				t.Errorf("Detect() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCadence(t *testing.T) {
+	history := readings(time.Now(), time.Minute, time.Minute, time.Minute)
+	if got := Cadence(settings.Poll{Cadence: "5"}, history); got != 5*time.Minute {
+		t.Errorf("a set cadence should be used, got %v", got)
+	}
+	if got := Cadence(settings.Poll{Cadence: "auto"}, history); got != time.Minute {
+		t.Errorf("auto should detect the cadence, got %v", got)
+	}
+}
+
+func TestNext(t *testing.T) {
+	last := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	cadence, delay, retry := 5*time.Minute, 10*time.Second, 30*time.Second
+	tests := []struct {
+		name  string
+		now   time.Time
+		retry time.Duration
+		want  time.Time
+	}{
+		{"on time", last.Add(20 * time.Second), retry, last.Add(5*time.Minute + 10*time.Second)},
+		{"late", last.Add(5*time.Minute + 11*time.Second), retry, last.Add(5*time.Minute + 41*time.Second)},
+		{"retries stop at the following reading", last.Add(9*time.Minute + 50*time.Second), retry, last.Add(10*time.Minute + 10*time.Second)},
+		{"missed", last.Add(10*time.Minute + 10*time.Second), retry, last.Add(15*time.Minute + 10*time.Second)},
+		{"missed for a while", last.Add(32 * time.Minute), retry, last.Add(35*time.Minute + 10*time.Second)},
+		{"no retries", last.Add(5*time.Minute + 11*time.Second), 0, last.Add(10*time.Minute + 10*time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Next(last, tt.now, cadence, delay, tt.retry); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got.Sub(last), tt.want.Sub(last))
+			}
+		})
+	}
+
+	now := last.Add(30 * time.Second)
+	if got := Next(last, now, time.Minute, delay, retry); !got.Equal(last.Add(70 * time.Second)) {
+		t.Errorf("Next() at a 1 minute cadence = %v", got.Sub(last))
+	}
+	if got := Next(time.Time{}, now, cadence, delay, retry); !got.Equal(now.Add(DefaultCadence)) {
+		t.Errorf("Next() without a reading = %v", got.Sub(now))
+	}
+}
@@ -0,0 +1,103 @@
+package power
+
+import "github.com/godbus/dbus/v5"
+
+const (
+	login1Interface  = "org.freedesktop.login1.Manager"
+	networkInterface = "org.freedesktop.NetworkManager"
+	networkPath      = "/org/freedesktop/NetworkManager"
+	// connectedGlobal is the NetworkManager state when there is internet access.
+	connectedGlobal = 70
+)
+
+// Event is a change after which the latest reading is likely to be stale.
+type Event string
+
+const (
+	Resumed   Event = "resumed from sleep"
+	Connected Event = "network connected"
+)
+
+// Listen connects to the system bus and sends an event when the computer resumes from sleep or
+// the network connects. Events are dropped while a previous one is waiting to be handled, as
+// resuming often reconnects the network straight after.
+func Listen(events chan<- Event) error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return err
+	}
+	err = conn.AddMatchSignal(dbus.WithMatchInterface(login1Interface), dbus.WithMatchMember("PrepareForSleep"))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	err = conn.AddMatchSignal(dbus.WithMatchInterface(networkInterface), dbus.WithMatchMember("StateChanged"))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	w := &watcher{connected: networkConnected(conn)}
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+	go func() {
+		for signal := range signals {
+			event, ok := w.handle(signal.Name, signal.Body)
+			if !ok {
+				continue
+			}
+			select {
+			case events <- event:
+			default:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// networkConnected reports whether NetworkManager has internet access, assuming it does when
+// NetworkManager isn't running.
+func networkConnected(conn *dbus.Conn) bool {
+	state, err := conn.Object(networkInterface, networkPath).GetProperty(networkInterface + ".State")
+	if err != nil {
+		return true
+	}
+	value, ok := state.Value().(uint32)
+
+	return !ok || value >= connectedGlobal
+}
+
+// watcher turns signals into events, tracking the network so resuming while offline waits for
+// the network to connect.
+type watcher struct {
+	connected bool
+}
+
+// handle gets the event for a signal, ok is false when the signal doesn't need a refresh.
+func (w *watcher) handle(name string, body []interface{}) (Event, bool) {
+	if len(body) == 0 {
+		return "", false
+	}
+	switch name {
+	case login1Interface + ".PrepareForSleep":
+		// the signal is sent with true before sleeping and false after resuming
+		sleeping, ok := body[0].(bool)
+		if !ok || sleeping || !w.connected {
+			return "", false
+		}
+
+		return Resumed, true
+	case networkInterface + ".StateChanged":
+		state, ok := body[0].(uint32)
+		if !ok {
+			return "", false
+		}
+		wasConnected := w.connected
+		w.connected = state >= connectedGlobal
+		if w.connected && !wasConnected {
+			return Connected, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,39 @@
+package power
+
+import "testing"
+
+func TestHandle(t *testing.T) {
+	sleep := login1Interface + ".PrepareForSleep"
+	network := networkInterface + ".StateChanged"
+	tests := []struct {
+		name      string
+		connected bool
+		signal    string
+		body      []interface{}
+		want      Event
+		ok        bool
+		after     bool
+	}{
+		{"going to sleep", true, sleep, []interface{}{true}, "", false, true},
+		{"resumed", true, sleep, []interface{}{false}, Resumed, true, true},
+		{"resumed offline", false, sleep, []interface{}{false}, "", false, false},
+		{"connected", false, network, []interface{}{uint32(70)}, Connected, true, true},
+		{"still connected", true, network, []interface{}{uint32(70)}, "", false, true},
+		{"disconnected", true, network, []interface{}{uint32(20)}, "", false, false},
+		{"local only", false, network, []interface{}{uint32(50)}, "", false, false},
+		{"other signal", true, networkInterface + ".DeviceAdded", []interface{}{uint32(70)}, "", false, true},
+		{"empty body", true, sleep, nil, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &watcher{connected: tt.connected}
+			event, ok := w.handle(tt.signal, tt.body)
+			if event != tt.want || ok != tt.ok {
+				t.Errorf("handle() = %q, %v, want %q, %v", event, ok, tt.want, tt.ok)
+			}
+			if w.connected != tt.after {
+				t.Errorf("connected = %v, want %v", w.connected, tt.after)
+			}
+		})
+	}
+}
@@ -10,67 +10,67 @@ import (
 	"time"
 
 	"github.com/brettcodling/SugarMateReader/internal/auth"
-	"github.com/brettcodling/SugarMateReader/internal/img"
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/metrics"
 	"github.com/brettcodling/SugarMateReader/internal/notify"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+)
+
+const (
+	// BackfillWindow is the furthest back missed readings are fetched.
+	BackfillWindow = 24 * time.Hour
+	// backfillChunk is the time covered by each request when backfilling.
+	backfillChunk = 6 * time.Hour
 )
 
 var LastUpdateTime string
 
-// GetReading gets the reading data from SugarMate and build the systray icon image.
-func GetReading(retry bool, before, after string) []byte {
+// GetReading gets the latest reading data from SugarMate.
+func GetReading(retry bool, before, after string) glucose.Reading {
 	if before == "" {
 		before = time.Now().Format(time.RFC3339Nano)
 	}
 	if after == "" {
 		after = time.Now().Add(-120 * time.Minute).Format(time.RFC3339Nano)
 	}
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(
-		"https://api.sugarmate.io/api/v3/events?before=%s&after=%s",
-		before,
-		after,
-	), nil)
+	body, status, err := fetchEvents(before, after)
 	if err != nil {
 		notify.Warning("ERROR!", err.Error())
 		log.Println("error:")
 		log.Println(err)
 
-		return []byte{}
+		return glucose.Reading{}
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", auth.Token.AccessToken))
-	transport := &http.Transport{}
-	resp, err := transport.RoundTrip(req)
+	if status != http.StatusOK {
+		if retry {
+			auth.GetAuth()
+			return GetReading(false, before, after)
+		}
+
+		return glucose.Reading{}
+	}
+	log.Println(string(body))
+	reading, history, err := parseReading(body)
 	if err != nil {
 		notify.Warning("ERROR!", err.Error())
 		log.Println("error:")
 		log.Println(err)
 
-		return []byte{}
+		return glucose.Reading{}
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	err = database.SaveReadings(history)
 	if err != nil {
-		notify.Warning("ERROR!", err.Error())
 		log.Println("error:")
 		log.Println(err)
-
-		return []byte{}
 	}
-	if resp.StatusCode != http.StatusOK {
-		if retry {
-			auth.GetAuth()
-			return GetReading(false, before, after)
-		}
-
-		return []byte{}
+	treatments, err := parseTreatments(body)
+	if err == nil {
+		err = database.SaveTreatments(treatments)
 	}
-	log.Println(string(body))
-	reading, err := parseReading(body)
 	if err != nil {
-		notify.Warning("ERROR!", err.Error())
 		log.Println("error:")
 		log.Println(err)
-
-		return []byte{}
 	}
 	if reading.MgDl < 1 {
 		if retry {
@@ -81,7 +81,79 @@ func GetReading(retry bool, before, after string) []byte {
 		log.Println("error:")
 		log.Fatal("Failed to get readings")
 	}
-	return img.BuildImage(reading.MgDl, reading.Trend, reading.Delta)
+	return reading
+}
+
+// Backfill fetches and stores the readings and treatments from the time until now, going back at
+// most BackfillWindow, for when readings were missed such as while the computer was asleep.
+func Backfill(from, now time.Time) error {
+	if from.Before(now.Add(-BackfillWindow)) {
+		from = now.Add(-BackfillWindow)
+	}
+	for start := from; start.Before(now); start = start.Add(backfillChunk) {
+		end := start.Add(backfillChunk)
+		if end.After(now) {
+			end = now
+		}
+		// the chunks overlap so the first reading of each has one to calculate its delta from
+		before, after := end.Format(time.RFC3339Nano), start.Add(-10*time.Minute).Format(time.RFC3339Nano)
+		body, status, err := fetchEvents(before, after)
+		if err == nil && status != http.StatusOK {
+			auth.GetAuth()
+			body, status, err = fetchEvents(before, after)
+		}
+		if err != nil {
+			return err
+		}
+		if status != http.StatusOK {
+			return fmt.Errorf("fetching readings failed with status %d", status)
+		}
+		_, history, err := parseReading(body)
+		if err != nil {
+			return err
+		}
+		err = database.SaveReadings(history)
+		if err != nil {
+			return err
+		}
+		treatments, err := parseTreatments(body)
+		if err != nil {
+			return err
+		}
+		err = database.SaveTreatments(treatments)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fetchEvents gets the body and status of the events between two times from SugarMate.
+func fetchEvents(before, after string) ([]byte, int, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(
+		"https://api.sugarmate.io/api/v3/events?before=%s&after=%s",
+		before,
+		after,
+	), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", auth.Token.AccessToken))
+	transport := &http.Transport{}
+	start := time.Now()
+	resp, err := transport.RoundTrip(req)
+	metrics.ObserveRequest("events", time.Since(start), err != nil || resp.StatusCode != http.StatusOK)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return body, resp.StatusCode, nil
 }
 
 type Response struct {
@@ -89,9 +161,13 @@ type Response struct {
 }
 
 type Event struct {
-	EventType string  `json:"event_type"`
-	CreatedAt string  `json:"created_at"`
-	Glucose   Glucose `json:"glucose"`
+	EventType string   `json:"event_type"`
+	CreatedAt string   `json:"created_at"`
+	Glucose   Glucose  `json:"glucose"`
+	Insulin   Insulin  `json:"insulin"`
+	Carbs     Carbs    `json:"carbs"`
+	Exercise  Exercise `json:"exercise"`
+	Note      string   `json:"note"`
 }
 
 type Glucose struct {
@@ -99,25 +175,70 @@ type Glucose struct {
 	Trend string `json:"trend"`
 }
 
-type CurrentReading struct {
-	MgDl  int
-	Trend string
-	Delta int
+type Insulin struct {
+	Units float64 `json:"units"`
+}
+
+type Carbs struct {
+	Grams float64 `json:"grams"`
+}
+
+type Exercise struct {
+	Minutes float64 `json:"minutes"`
+}
+
+// parseTreatments parses every event in the response which isn't a glucose reading. Event types
+// without an amount, such as site changes, are kept with just their note.
+func parseTreatments(body []byte) ([]treatment.Treatment, error) {
+	var response Response
+	err := json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	var treatments []treatment.Treatment
+	for _, event := range response.Events {
+		if event.EventType == "glucose" || event.EventType == "" {
+			continue
+		}
+		created, err := time.Parse(time.RFC3339Nano, event.CreatedAt)
+		if err != nil {
+			continue
+		}
+		t := treatment.Treatment{
+			Time:   created,
+			Type:   event.EventType,
+			Note:   event.Note,
+			Source: glucose.Source,
+		}
+		switch event.EventType {
+		case treatment.Insulin:
+			t.Amount = event.Insulin.Units
+		case treatment.Carbs:
+			t.Amount = event.Carbs.Grams
+		case treatment.Exercise:
+			t.Amount = event.Exercise.Minutes
+		}
+		treatments = append(treatments, t)
+	}
+
+	return treatments, nil
 }
 
-func parseReading(body []byte) (CurrentReading, error) {
-	var currentReading CurrentReading
+// parseReading parses the latest reading along with the history of every reading in the response.
+func parseReading(body []byte) (glucose.Reading, []glucose.Reading, error) {
+	var currentReading glucose.Reading
 	var response Response
 	err := json.Unmarshal(body, &response)
 	if err != nil {
-		return currentReading, err
+		return currentReading, nil, err
 	}
 
 	events := slices.DeleteFunc(response.Events, func(e Event) bool {
 		return e.EventType != "glucose"
 	})
 	if len(events) < 2 {
-		return currentReading, nil
+		return currentReading, nil, nil
 	}
 
 	slices.SortFunc(events, func(a, b Event) int {
@@ -130,9 +251,17 @@ func parseReading(body []byte) (CurrentReading, error) {
 		return 1
 	})
 
+	// the oldest event has nothing to calculate a delta from so it isn't included
+	history := make([]glucose.Reading, len(events)-1)
+	for i := range history {
+		history[i].Time, _ = time.Parse(time.RFC3339Nano, events[i].CreatedAt)
+		history[i].MgDl = events[i].Glucose.MgDl
+		history[i].Trend = events[i].Glucose.Trend
+		history[i].Delta = events[i].Glucose.MgDl - events[i+1].Glucose.MgDl
+		history[i].Source = glucose.Source
+	}
+
 	LastUpdateTime = events[0].CreatedAt
-	currentReading.MgDl = events[0].Glucose.MgDl
-	currentReading.Trend = events[0].Glucose.Trend
-	currentReading.Delta = currentReading.MgDl - events[1].Glucose.MgDl
-	return currentReading, nil
+	currentReading = history[0]
+	return currentReading, history, nil
 }
@@ -0,0 +1,78 @@
+package readings
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+)
+
+func TestParseTreatments(t *testing.T) {
+	created := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		event string
+		want  []treatment.Treatment
+	}{
+		{
+			"insulin",
+			`{"event_type": "insulin", "created_at": "2024-03-01T08:00:00Z", "insulin": {"units": 4.5}}`,
+			[]treatment.Treatment{{Time: created, Type: treatment.Insulin, Amount: 4.5, Source: glucose.Source}},
+		},
+		{
+			"carbs",
+			`{"event_type": "carbs", "created_at": "2024-03-01T08:00:00Z", "carbs": {"grams": 30}, "note": "toast"}`,
+			[]treatment.Treatment{{Time: created, Type: treatment.Carbs, Amount: 30, Note: "toast", Source: glucose.Source}},
+		},
+		{
+			"exercise",
+			`{"event_type": "exercise", "created_at": "2024-03-01T08:00:00Z", "exercise": {"minutes": 45}}`,
+			[]treatment.Treatment{{Time: created, Type: treatment.Exercise, Amount: 45, Source: glucose.Source}},
+		},
+		{
+			"unknown type kept with its note",
+			`{"event_type": "site_change", "created_at": "2024-03-01T08:00:00Z", "insulin": {"units": 4}, "note": "new site"}`,
+			[]treatment.Treatment{{Time: created, Type: "site_change", Note: "new site", Source: glucose.Source}},
+		},
+		{
+			"glucose",
+			`{"event_type": "glucose", "created_at": "2024-03-01T08:00:00Z", "glucose": {"mg_dl": 120}}`,
+			nil,
+		},
+		{
+			"no type",
+			`{"created_at": "2024-03-01T08:00:00Z", "note": "missing type"}`,
+			nil,
+		},
+		{
+			"bad created_at",
+			`{"event_type": "insulin", "created_at": "yesterday", "insulin": {"units": 4}}`,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTreatments([]byte(`{"events": [` + tt.event + `]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseTreatments() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Time.Equal(tt.want[i].Time) || got[i].Type != tt.want[i].Type || got[i].Amount != tt.want[i].Amount ||
+					got[i].Note != tt.want[i].Note || got[i].Source != tt.want[i].Source {
+					t.Errorf("parseTreatments() = %+v, want %+v", got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseTreatmentsInvalidBody(t *testing.T) {
+	_, err := parseTreatments([]byte(`{"events": `))
+	if err == nil {
+		t.Error("expected an error parsing an invalid body")
+	}
+}
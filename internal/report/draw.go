@@ -0,0 +1,223 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	"github.com/fogleman/gg"
+)
+
+const (
+	width = 1200
+	// maxMgDl is the highest level shown on the charts.
+	maxMgDl = 400
+	// stripsPerRow is the number of daily strips drawn on each row.
+	stripsPerRow = 7
+)
+
+var (
+	background = color.White
+	text       = color.RGBA{33, 33, 33, 255}
+	grid       = color.RGBA{224, 224, 224, 255}
+	target     = color.RGBA{76, 175, 80, 255}
+	targetFill = color.NRGBA{76, 175, 80, 40}
+	outerBand  = color.RGBA{144, 202, 249, 255}
+	innerBand  = color.RGBA{66, 165, 245, 255}
+	median     = color.RGBA{13, 71, 161, 255}
+)
+
+// PNG draws the whole report, the stats block followed by the profile and the daily strips.
+func PNG(report Report, setting settings.Setting, font string) ([]byte, error) {
+	profile, err := ProfileImage(report, setting, font)
+	if err != nil {
+		return nil, err
+	}
+	strips, err := StripsImage(report, setting, font)
+	if err != nil {
+		return nil, err
+	}
+	rows := report.Stats.Rows(setting)
+	statsHeight := 40 + 30*((len(rows)+1)/2)
+	context := gg.NewContext(width, 80+statsHeight+profile.Bounds().Dy()+strips.Bounds().Dy())
+	context.SetColor(background)
+	context.Clear()
+	context.SetColor(text)
+	err = context.LoadFontFace(font, 28)
+	if err != nil {
+		return nil, err
+	}
+	context.DrawString("Ambulatory Glucose Profile", 40, 50)
+	err = context.LoadFontFace(font, 16)
+	if err != nil {
+		return nil, err
+	}
+	context.DrawStringAnchored(Period(report), width-40, 50, 1, 0)
+	for i, row := range rows {
+		x := 40 + float64(i%2)*(width/2)
+		y := 110 + float64(i/2)*30
+		context.DrawString(row.Label, x, y)
+		context.DrawStringAnchored(row.Value, x+width/2-120, y, 1, 0)
+	}
+	context.DrawImage(profile, 0, 80+statsHeight)
+	context.DrawImage(strips, 0, 80+statsHeight+profile.Bounds().Dy())
+
+	buf := new(bytes.Buffer)
+	err = context.EncodePNG(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Period describes the days the report covers.
+func Period(report Report) string {
+	return fmt.Sprintf("%s - %s (%d days)", report.From.Format("2 Jan 2006"), report.To.Format("2 Jan 2006"), len(report.Days))
+}
+
+// ProfileImage draws the percentile bands of the readings by time of day.
+func ProfileImage(report Report, setting settings.Setting, font string) (image.Image, error) {
+	context := gg.NewContext(width, 480)
+	context.SetColor(background)
+	context.Clear()
+	err := context.LoadFontFace(font, 14)
+	if err != nil {
+		return nil, err
+	}
+	left, top, right, bottom := 80.0, 20.0, float64(width-40), 440.0
+	x := func(minute float64) float64 {
+		return left + (right-left)*minute/(24*60)
+	}
+	y := func(mgdl float64) float64 {
+		return bottom - (bottom-top)*math.Min(mgdl, maxMgDl)/maxMgDl
+	}
+	drawAxes(context, report, setting, left, top, right, bottom, y)
+	for hour := 0; hour <= 24; hour += 3 {
+		context.SetColor(text)
+		context.DrawStringAnchored(fmt.Sprintf("%02d:00", hour%24), x(float64(hour*60)), bottom+20, 0.5, 0.5)
+	}
+
+	// bands are drawn over each run of bins holding readings so gaps in the data stay visible
+	for _, run := range runs(report.Profile) {
+		center := func(bin Bin) float64 {
+			return x(float64(bin.Minute) + binMinutes/2)
+		}
+		for _, band := range []struct {
+			lower, upper int
+			colour       color.Color
+		}{{0, 4, outerBand}, {1, 3, innerBand}} {
+			for _, bin := range run {
+				context.LineTo(center(bin), y(bin.Percentiles[band.upper]))
+			}
+			for i := len(run) - 1; i >= 0; i-- {
+				context.LineTo(center(run[i]), y(run[i].Percentiles[band.lower]))
+			}
+			context.ClosePath()
+			context.SetColor(band.colour)
+			context.Fill()
+		}
+		for _, bin := range run {
+			context.LineTo(center(bin), y(bin.Percentiles[2]))
+		}
+		context.SetColor(median)
+		context.SetLineWidth(3)
+		context.Stroke()
+	}
+
+	return context.Image(), nil
+}
+
+// StripsImage draws the readings of each day on its own strip.
+func StripsImage(report Report, setting settings.Setting, font string) (image.Image, error) {
+	rows := (len(report.Days) + stripsPerRow - 1) / stripsPerRow
+	context := gg.NewContext(width, 40+rows*170)
+	context.SetColor(background)
+	context.Clear()
+	err := context.LoadFontFace(font, 14)
+	if err != nil {
+		return nil, err
+	}
+	stripWidth := float64(width-80) / stripsPerRow
+	for i, day := range report.Days {
+		left := 40 + float64(i%stripsPerRow)*stripWidth
+		top := 30 + float64(i/stripsPerRow)*170
+		right, bottom := left+stripWidth-10, top+130
+		x := func(reading glucose.Reading) float64 {
+			return left + (right-left)*reading.Time.Sub(day.Date).Minutes()/(24*60)
+		}
+		y := func(mgdl float64) float64 {
+			return bottom - (bottom-top)*math.Min(mgdl, maxMgDl)/maxMgDl
+		}
+		context.SetColor(text)
+		context.DrawStringAnchored(day.Date.Format("Mon 2 Jan"), (left+right)/2, top-12, 0.5, 0.5)
+		context.SetColor(grid)
+		context.SetLineWidth(1)
+		context.DrawRectangle(left, top, right-left, bottom-top)
+		context.Stroke()
+		context.SetColor(targetFill)
+		context.DrawRectangle(left, y(report.High), right-left, y(report.Low)-y(report.High))
+		context.Fill()
+		var previous glucose.Reading
+		for _, reading := range day.Readings {
+			// a gap of more than a few missed readings breaks the line
+			if previous.Time.IsZero() || reading.Time.Sub(previous.Time).Minutes() > 20 {
+				context.Stroke()
+				context.MoveTo(x(reading), y(float64(reading.MgDl)))
+			} else {
+				context.LineTo(x(reading), y(float64(reading.MgDl)))
+			}
+			previous = reading
+		}
+		context.SetColor(median)
+		context.SetLineWidth(1.5)
+		context.Stroke()
+	}
+
+	return context.Image(), nil
+}
+
+// drawAxes draws the target range and the level grid lines labelled in the units of the settings.
+func drawAxes(context *gg.Context, report Report, setting settings.Setting, left, top, right, bottom float64, y func(float64) float64) {
+	context.SetColor(grid)
+	context.SetLineWidth(1)
+	context.DrawRectangle(left, top, right-left, bottom-top)
+	context.Stroke()
+	for _, level := range []float64{54, report.Low, report.High, 250, 350} {
+		colour := grid
+		if level == report.Low || level == report.High {
+			colour = target
+		}
+		context.SetColor(colour)
+		context.SetLineWidth(2)
+		context.DrawLine(left, y(level), right, y(level))
+		context.Stroke()
+		context.SetColor(text)
+		context.DrawStringAnchored(setting.Number(glucose.Value(level, setting.Units), setting.Units), left-10, y(level), 1, 0.5)
+	}
+}
+
+// runs splits the bins into runs of consecutive bins which hold readings.
+func runs(bins []Bin) [][]Bin {
+	var runs [][]Bin
+	var run []Bin
+	for _, bin := range bins {
+		if bin.Readings == 0 {
+			if len(run) > 0 {
+				runs = append(runs, run)
+			}
+			run = nil
+			continue
+		}
+		run = append(run, bin)
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+
+	return runs
+}
@@ -0,0 +1,71 @@
+package report
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"image"
+	"image/png"
+
+	_ "embed"
+
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	"github.com/brettcodling/SugarMateReader/internal/stats"
+)
+
+//go:embed report.tmpl
+var reportTmpl string
+
+// page is the data of the HTML report.
+type page struct {
+	Period  string
+	Stats   []stats.Row
+	Profile template.URL
+	Strips  template.URL
+}
+
+// HTML renders the report as a standalone HTML page with the charts embedded as images.
+func HTML(report Report, setting settings.Setting, font string) ([]byte, error) {
+	profile, err := ProfileImage(report, setting, font)
+	if err != nil {
+		return nil, err
+	}
+	strips, err := StripsImage(report, setting, font)
+	if err != nil {
+		return nil, err
+	}
+	data := page{
+		Period: Period(report),
+		Stats:  report.Stats.Rows(setting),
+	}
+	data.Profile, err = dataURL(profile)
+	if err != nil {
+		return nil, err
+	}
+	data.Strips, err = dataURL(strips)
+	if err != nil {
+		return nil, err
+	}
+	t, err := template.New("report").Parse(reportTmpl)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	err = t.Execute(buf, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// dataURL encodes the image as a PNG data URL.
+func dataURL(img image.Image) (template.URL, error) {
+	buf := new(bytes.Buffer)
+	err := png.Encode(buf, img)
+	if err != nil {
+		return "", err
+	}
+
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())), nil
+}
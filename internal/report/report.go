@@ -0,0 +1,118 @@
+package report
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	"github.com/brettcodling/SugarMateReader/internal/stats"
+)
+
+const (
+	// Days is the number of days of history an AGP report covers.
+	Days = 14
+	// binMinutes is the width of the time of day bins the profile is built from.
+	binMinutes = 15
+)
+
+// Percentiles are the percentiles of the profile bands, in the order they are held.
+var Percentiles = []float64{5, 25, 50, 75, 95}
+
+// Report is an ambulatory glucose profile of the readings over a number of days, levels are in mg/dl.
+type Report struct {
+	From    time.Time
+	To      time.Time
+	Low     float64
+	High    float64
+	Stats   stats.Stats
+	Profile []Bin
+	Days    []Day
+}
+
+// Bin holds the percentiles of the readings taken in a window of the time of day.
+type Bin struct {
+	// Minute is the minute of the day the bin starts at.
+	Minute      int
+	Readings    int
+	Percentiles []float64
+}
+
+// Day holds the readings taken on a day.
+type Day struct {
+	Date     time.Time
+	Readings []glucose.Reading
+}
+
+// Generate builds the report of the stored readings over the days up to and including today in
+// the range of the settings, history gets the stored readings between two times.
+func Generate(setting settings.Setting, now time.Time, history func(from, to time.Time) ([]glucose.Reading, error)) (Report, error) {
+	low, high, err := stats.Range(setting)
+	if err != nil {
+		return Report{}, err
+	}
+	readings, err := history(now.AddDate(0, 0, -Days-1), now)
+	if err != nil {
+		return Report{}, err
+	}
+
+	return Build(readings, now, low, high), nil
+}
+
+// Build builds the report of the readings over the days leading up to the end of the day of to,
+// low and high are the target range in mg/dl.
+func Build(readings []glucose.Reading, to time.Time, low, high float64) Report {
+	end := time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, to.Location())
+	report := Report{
+		From: end.AddDate(0, 0, -Days),
+		To:   end.Add(-time.Nanosecond),
+		Low:  low,
+		High: high,
+	}
+	readings = slices.DeleteFunc(slices.Clone(readings), func(reading glucose.Reading) bool {
+		return reading.Missing() || reading.Time.Before(report.From) || !reading.Time.Before(end)
+	})
+	slices.SortFunc(readings, func(a, b glucose.Reading) int {
+		return a.Time.Compare(b.Time)
+	})
+	report.Stats = stats.Calculate(readings, low, high)
+
+	bins := make([][]float64, 24*60/binMinutes)
+	for _, reading := range readings {
+		local := reading.Time.In(to.Location())
+		bin := (local.Hour()*60 + local.Minute()) / binMinutes
+		bins[bin] = append(bins[bin], float64(reading.MgDl))
+	}
+	for i, values := range bins {
+		slices.Sort(values)
+		bin := Bin{Minute: i * binMinutes, Readings: len(values)}
+		if len(values) > 0 {
+			for _, p := range Percentiles {
+				bin.Percentiles = append(bin.Percentiles, percentile(values, p))
+			}
+		}
+		report.Profile = append(report.Profile, bin)
+	}
+
+	for day := report.From; day.Before(end); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		report.Days = append(report.Days, Day{
+			Date: day,
+			Readings: slices.DeleteFunc(slices.Clone(readings), func(reading glucose.Reading) bool {
+				return reading.Time.Before(day) || !reading.Time.Before(next)
+			}),
+		})
+	}
+
+	return report
+}
+
+// percentile gets the percentile of the sorted values, interpolating between the closest values.
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,98 @@
+package report
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+const font = "../../assets/Roboto-Bold.ttf"
+
+func testSetting() settings.Setting {
+	return settings.Setting{Format: "%.1f", Units: "mmol", Range: settings.Range{Low: "3.9", High: "10.0"}}
+}
+
+// history gets readings every 5 minutes for the days before to, following a daily curve.
+func history(to time.Time, days int) []glucose.Reading {
+	var readings []glucose.Reading
+	for at := to.AddDate(0, 0, -days); at.Before(to); at = at.Add(5 * time.Minute) {
+		hour := float64(at.Hour()) + float64(at.Minute())/60
+		mgdl := 130 + 50*math.Sin(hour/24*2*math.Pi) + float64(at.YearDay()%5)*10
+		readings = append(readings, glucose.Reading{Time: at, MgDl: int(mgdl)})
+	}
+
+	return readings
+}
+
+func TestBuild(t *testing.T) {
+	to := time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC)
+	readings := history(to, 20)
+	report := Build(readings, to, 70, 180)
+
+	if !report.From.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || len(report.Days) != Days {
+		t.Errorf("from = %s, days = %d", report.From, len(report.Days))
+	}
+	if last := report.Days[Days-1]; !last.Date.Equal(time.Date(2024, 3, 14, 0, 0, 0, 0, time.UTC)) || len(last.Readings) != 144 {
+		t.Errorf("last day = %s with %d readings", last.Date, len(last.Readings))
+	}
+	if len(report.Profile) != 96 {
+		t.Fatalf("profile has %d bins, want 96", len(report.Profile))
+	}
+	for _, bin := range report.Profile {
+		if bin.Readings == 0 {
+			t.Fatalf("bin at minute %d has no readings", bin.Minute)
+		}
+		for i := 1; i < len(bin.Percentiles); i++ {
+			if bin.Percentiles[i] < bin.Percentiles[i-1] {
+				t.Fatalf("percentiles at minute %d are out of order: %v", bin.Minute, bin.Percentiles)
+			}
+		}
+	}
+	if report.Stats.Readings == 0 || report.Stats.Readings > Days*288 {
+		t.Errorf("stats cover %d readings", report.Stats.Readings)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5}
+	for p, want := range map[float64]float64{0: 1, 25: 2, 50: 3, 95: 4.8, 100: 5} {
+		if got := percentile(values, p); math.Abs(got-want) > 1e-9 {
+			t.Errorf("percentile %.0f = %f, want %f", p, got, want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	to := time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC)
+	// a day without readings leaves a gap in the profile and an empty strip
+	readings := history(to, 14)
+	report := Build(readings[:len(readings)-200], to, 70, 180)
+
+	image, err := PNG(report, testSetting(), font)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := png.Decode(bytes.NewReader(image)); err != nil {
+		t.Fatal(err)
+	}
+	if path := os.Getenv("REPORT_OUTPUT"); path != "" {
+		os.WriteFile(path, image, 0644)
+	}
+
+	page, err := HTML(report, testSetting(), font)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"1 Mar 2024 - 14 Mar 2024 (14 days)", "In range", `src="data:image/png;base64,`} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("page is missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package settings
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+)
+
+// Display configures how glucose values are shown. Both shows the other units alongside the
+// selected units, Decimals is the precision of values in the selected units, Sign shows a + on
+// rising changes and Separator is the decimal separator, "." or "," or "auto" to follow the locale.
+type Display struct {
+	Both      string
+	Decimals  string
+	Sign      string
+	Separator string
+}
+
+// commaLanguages are the languages which write decimals with a comma.
+var commaLanguages = []string{
+	"bg", "cs", "da", "de", "el", "es", "et", "fi", "fr", "hr", "hu", "id", "it", "lt", "lv", "nb",
+	"nl", "nn", "no", "pl", "pt", "ro", "ru", "sk", "sl", "sr", "sv", "tr", "uk", "vi",
+}
+
+// LocaleSeparator gets the decimal separator of the locale in the environment.
+func LocaleSeparator() string {
+	locale := ""
+	for _, name := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if locale = os.Getenv(name); locale != "" {
+			break
+		}
+	}
+	language, _, _ := strings.Cut(strings.ToLower(locale), "_")
+	language, _, _ = strings.Cut(language, ".")
+	for _, comma := range commaLanguages {
+		if language == comma {
+			return ","
+		}
+	}
+
+	return "."
+}
+
+// DefaultDecimals gets the precision values in the units are shown with by default.
+func DefaultDecimals(units string) int {
+	if units == "mgdl" {
+		return 0
+	}
+
+	return 1
+}
+
+// ValueFormat gets the format of values in the units with the chosen number of decimals, using the
+// default for the units when it isn't set.
+func ValueFormat(units, decimals string) string {
+	precision, err := strconv.Atoi(decimals)
+	if err != nil || precision < 0 || precision > 3 {
+		precision = DefaultDecimals(units)
+	}
+
+	return fmt.Sprintf("%%.%df", precision)
+}
+
+// Precision gets the number of decimals values in the selected units are shown with.
+func (s Setting) Precision() int {
+	precision, err := strconv.Atoi(s.Display.Decimals)
+	if err != nil || precision < 0 || precision > 3 {
+		return DefaultDecimals(s.Units)
+	}
+
+	return precision
+}
+
+// Localise swaps the decimal point of a formatted number for the chosen separator.
+func (s Setting) Localise(formatted string) string {
+	separator := s.Display.Separator
+	if separator == "auto" {
+		separator = LocaleSeparator()
+	}
+	if separator != "," {
+		return formatted
+	}
+
+	return strings.ReplaceAll(formatted, ".", ",")
+}
+
+// UnitFormat gets the format of values in the units, the selected units use the chosen precision.
+func (s Setting) UnitFormat(units string) string {
+	if units != s.Units || s.Format == "" {
+		return ValueFormat(units, "")
+	}
+
+	return s.Format
+}
+
+// RateFormat gets the format of a rate of change in the units, the change each minute is small so
+// it's shown with an extra decimal.
+func (s Setting) RateFormat(units string) string {
+	if s.Rate.Minutes() != 1 {
+		return s.UnitFormat(units)
+	}
+	precision := DefaultDecimals(units)
+	if units == s.Units {
+		precision = s.Precision()
+	}
+
+	return fmt.Sprintf("%%.%df", precision+1)
+}
+
+// Number formats a value in the units with the decimal separator.
+func (s Setting) Number(value float64, units string) string {
+	return s.Localise(fmt.Sprintf(s.UnitFormat(units), value))
+}
+
+// Value formats a mg/dl value in the selected units, followed by the other units when both are
+// shown, such as "6.2 | 112".
+func (s Setting) Value(mgdl float64) string {
+	value := s.Number(glucose.Value(mgdl, s.Units), s.Units)
+	if s.Display.Both != "true" {
+		return value
+	}
+	other := "mgdl"
+	if s.Units == "mgdl" {
+		other = "mmol"
+	}
+
+	return value + " | " + s.Number(glucose.Value(mgdl, other), other)
+}
+
+// Change formats a change in the selected units, with a + when it's rising and Sign is set.
+func (s Setting) Change(value float64, format string) string {
+	formatted := fmt.Sprintf(format, value)
+	if s.Display.Sign == "true" && value > 0 && strings.Trim(formatted, "0.") != "" {
+		formatted = "+" + formatted
+	}
+
+	return s.Localise(formatted)
+}
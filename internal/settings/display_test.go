@@ -0,0 +1,67 @@
+package settings
+
+import "testing"
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		setting Setting
+		want    string
+	}{
+		{"mmol", Setting{Units: "mmol", Format: "%.1f"}, "6.2"},
+		{"mgdl", Setting{Units: "mgdl", Format: "%.0f"}, "112"},
+		{"both", Setting{Units: "mmol", Format: "%.1f", Display: Display{Both: "true"}}, "6.2 | 112"},
+		{"both mgdl", Setting{Units: "mgdl", Format: "%.0f", Display: Display{Both: "true"}}, "112 | 6.2"},
+		{"decimals", Setting{Units: "mmol", Format: ValueFormat("mmol", "2"), Display: Display{Both: "true"}}, "6.22 | 112"},
+		{"comma", Setting{Units: "mmol", Format: "%.1f", Display: Display{Both: "true", Separator: ","}}, "6,2 | 112"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.setting.Value(112); got != tt.want {
+				t.Errorf("Value() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChange(t *testing.T) {
+	setting := Setting{Units: "mmol", Format: "%.1f"}
+	if got := setting.Change(0.5, "%.1f"); got != "0.5" {
+		t.Errorf("Change() = %q without a sign", got)
+	}
+	setting.Display = Display{Sign: "true", Separator: ","}
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0.5, "+0,5"},
+		{-0.5, "-0,5"},
+		{0.01, "0,0"},
+	}
+	for _, tt := range tests {
+		if got := setting.Change(tt.value, "%.1f"); got != tt.want {
+			t.Errorf("Change(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestLocaleSeparator(t *testing.T) {
+	tests := []struct {
+		all, numeric, lang string
+		want               string
+	}{
+		{"", "", "en_GB.UTF-8", "."},
+		{"", "", "de_DE.UTF-8", ","},
+		{"", "fr_FR.UTF-8", "en_US.UTF-8", ","},
+		{"C", "de_DE.UTF-8", "de_DE.UTF-8", "."},
+		{"", "", "", "."},
+	}
+	for _, tt := range tests {
+		t.Setenv("LC_ALL", tt.all)
+		t.Setenv("LC_NUMERIC", tt.numeric)
+		t.Setenv("LANG", tt.lang)
+		if got := LocaleSeparator(); got != tt.want {
+			t.Errorf("LocaleSeparator() with %q, %q, %q = %q, want %q", tt.all, tt.numeric, tt.lang, got, tt.want)
+		}
+	}
+}
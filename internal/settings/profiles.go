@@ -0,0 +1,78 @@
+package settings
+
+import "time"
+
+// ProfileNames are the alert profiles in the order they are shown.
+var ProfileNames = []string{"day", "night", "exercise", "school"}
+
+// ScheduleDayNames are the day options of a schedule entry in the order they are shown.
+var ScheduleDayNames = []string{"daily", "weekdays", "weekends", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+
+// ScheduleDays are the days each schedule day option runs on.
+var ScheduleDays = map[string][]time.Weekday{
+	"daily":     {time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday},
+	"weekdays":  {time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	"weekends":  {time.Saturday, time.Sunday},
+	"sunday":    {time.Sunday},
+	"monday":    {time.Monday},
+	"tuesday":   {time.Tuesday},
+	"wednesday": {time.Wednesday},
+	"thursday":  {time.Thursday},
+	"friday":    {time.Friday},
+	"saturday":  {time.Saturday},
+}
+
+// Profile is a named set of alert settings.
+type Profile struct {
+	Name   string
+	Alerts Alert
+}
+
+// Schedule switches to a profile at a time of day on the given days.
+type Schedule struct {
+	Days    string
+	Time    string
+	Profile string
+}
+
+// Weekdays gets the days the schedule entry runs on.
+func (s Schedule) Weekdays() []time.Weekday {
+	return ScheduleDays[s.Days]
+}
+
+// ProfileAlerts gets the alert settings of a profile.
+func (s Setting) ProfileAlerts(name string) (Alert, bool) {
+	for _, profile := range s.Profiles {
+		if profile.Name == name {
+			return profile.Alerts, true
+		}
+	}
+
+	return Alert{}, false
+}
+
+// ScheduledProfile gets the profile the schedule most recently switched to before now.
+func ScheduledProfile(schedule []Schedule, now time.Time) (string, bool) {
+	var latest time.Time
+	profile := ""
+	for _, entry := range schedule {
+		at, err := time.ParseInLocation("15:04", entry.Time, now.Location())
+		if err != nil {
+			continue
+		}
+		for _, weekday := range entry.Weekdays() {
+			daysAgo := (int(now.Weekday()) - int(weekday) + 7) % 7
+			day := now.AddDate(0, 0, -daysAgo)
+			switched := time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+			if switched.After(now) {
+				switched = switched.AddDate(0, 0, -7)
+			}
+			if switched.After(latest) {
+				latest = switched
+				profile = entry.Profile
+			}
+		}
+	}
+
+	return profile, profile != ""
+}
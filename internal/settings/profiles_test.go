@@ -0,0 +1,39 @@
+package settings
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduledProfile(t *testing.T) {
+	schedule := []Schedule{
+		{Days: "daily", Time: "07:00", Profile: "day"},
+		{Days: "daily", Time: "22:00", Profile: "night"},
+		{Days: "weekdays", Time: "08:30", Profile: "school"},
+		{Days: "weekdays", Time: "15:30", Profile: "day"},
+		{Days: "saturday", Time: "10:00", Profile: "exercise"},
+	}
+	// 2024-06-03 is a Monday
+	tests := []struct {
+		now  time.Time
+		want string
+	}{
+		{time.Date(2024, 6, 3, 6, 0, 0, 0, time.UTC), "night"},
+		{time.Date(2024, 6, 3, 7, 30, 0, 0, time.UTC), "day"},
+		{time.Date(2024, 6, 3, 9, 0, 0, 0, time.UTC), "school"},
+		{time.Date(2024, 6, 3, 16, 0, 0, 0, time.UTC), "day"},
+		{time.Date(2024, 6, 3, 23, 0, 0, 0, time.UTC), "night"},
+		{time.Date(2024, 6, 8, 11, 0, 0, 0, time.UTC), "exercise"},
+		{time.Date(2024, 6, 9, 9, 0, 0, 0, time.UTC), "day"},
+	}
+	for _, tt := range tests {
+		got, ok := ScheduledProfile(schedule, tt.now)
+		if !ok || got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.now, tt.want, got)
+		}
+	}
+
+	if _, ok := ScheduledProfile(nil, time.Now()); ok {
+		t.Error("expected no profile for an empty schedule")
+	}
+}
@@ -0,0 +1,163 @@
+package settings
+
+import (
+	"strconv"
+	"time"
+)
+
+type Setting struct {
+	// Alerts are the alert settings of the active profile.
+	Alerts   Alert
+	Display  Display
+	Email    Email
+	Format   string
+	Influx   Influx
+	Insulin  Insulin
+	MQTT     MQTT
+	Poll     Poll
+	Profile  string
+	Profiles []Profile
+	Range    Range
+	Rate     Rate
+	Saved    bool
+	Schedule []Schedule
+	Units    string
+	Webhooks []Webhook
+}
+
+type Alert struct {
+	LowEnabled           string
+	Low                  string
+	LowRepeat            string
+	HighEnabled          string
+	High                 string
+	HighRepeat           string
+	FastChangeEnabled    string
+	FastChange           string
+	FastChangeRepeat     string
+	PredictiveLowEnabled string
+	PredictiveLow        string
+	// Escalate sounds an alarm after EscalateSound minutes and sends a critical notification after
+	// EscalateCritical minutes while an escalating alert is unacknowledged.
+	Escalate         string
+	EscalateSound    string
+	EscalateCritical string
+}
+
+// Webhook posts alerts for the selected rules, and every reading when Readings is "true", to a URL.
+type Webhook struct {
+	URL      string
+	Secret   string
+	Alerts   []string
+	Readings string
+}
+
+// Email sends alerts through an SMTP server using STARTTLS, each alert is emailed at most once
+// every Interval minutes.
+type Email struct {
+	Enabled  string
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	// To is a comma separated list of recipients.
+	To       string
+	Interval string
+}
+
+// Influx writes readings in line protocol to an InfluxDB server through the v2 write API, to a
+// local File, or to both.
+type Influx struct {
+	Enabled string
+	URL     string
+	Org     string
+	Bucket  string
+	Token   string
+	File    string
+}
+
+// Insulin describes how insulin and carbs act, for working out the insulin and carbs on board.
+// Curve is rapid, ultra-rapid or custom, custom curves peak after Peak minutes. DIA is the duration
+// of insulin action in hours. Sensitivity is the drop in glucose per unit of insulin in mg/dl,
+// CarbRatio is the grams of carbs covered by a unit and CarbRate is the grams of carbs absorbed
+// each hour.
+type Insulin struct {
+	Curve       string
+	DIA         string
+	Peak        string
+	Sensitivity string
+	CarbRatio   string
+	CarbRate    string
+}
+
+// MQTT publishes readings and alerts to a broker under the Topic prefix, along with Home Assistant
+// discovery config when Discovery is "true".
+type MQTT struct {
+	Enabled   string
+	Broker    string
+	Username  string
+	Password  string
+	Topic     string
+	Discovery string
+}
+
+// Poll configures when readings are fetched. Cadence is the minutes between readings or "auto" to
+// detect it, Delay is the seconds after a reading is expected that it's fetched and Retry is how
+// many seconds apart a late reading is fetched again, 0 turns retries off.
+type Poll struct {
+	Cadence string
+	Delay   string
+	Retry   string
+}
+
+type Range struct {
+	Low  string
+	High string
+}
+
+// Rate configures how the rate of change is calculated and displayed.
+type Rate struct {
+	// Window is the number of minutes of readings the rate is calculated over.
+	Window string
+	// Per is the number of minutes the displayed rate covers.
+	Per string
+}
+
+// WindowDuration gets the window the rate is calculated over.
+func (r Rate) WindowDuration() time.Duration {
+	window, err := strconv.Atoi(r.Window)
+	if err != nil || window < 1 {
+		window = 15
+	}
+
+	return time.Duration(window) * time.Minute
+}
+
+// Minutes gets the number of minutes the displayed rate covers.
+func (r Rate) Minutes() float64 {
+	if r.Per == "1" {
+		return 1
+	}
+
+	return 5
+}
+
+// Label describes the time the displayed rate covers.
+func (r Rate) Label() string {
+	if r.Per == "1" {
+		return "/min"
+	}
+
+	return "/5min"
+}
+
+// Minutes parses a setting holding a number of minutes.
+func Minutes(value string) time.Duration {
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0
+	}
+
+	return time.Duration(parsed) * time.Minute
+}
@@ -0,0 +1,48 @@
+package settings
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+)
+
+// Thresholds, the target range, alert levels and insulin sensitivity, are stored in mg/dl whatever
+// the selected units and only converted to be shown.
+
+// Level parses a threshold stored in mg/dl.
+func Level(mgdl string) (float64, error) {
+	return strconv.ParseFloat(mgdl, 64)
+}
+
+// Round converts a mg/dl value into the selected units, rounded to a whole mg/dl or a tenth of a
+// mmol/l. Readings are compared with thresholds once both are rounded, so an alert fires when the
+// value reaches the threshold as entered. The precision is fixed rather than the one chosen for
+// display, as whole mmol/l would make a 0.5 mmol/l fast change level 0.
+func (s Setting) Round(mgdl float64) float64 {
+	return glucose.Round(mgdl, s.Units)
+}
+
+// ToUnits formats a threshold stored in mg/dl in the selected units, as it's entered in the
+// settings form. Blank and invalid values are left as they are.
+func (s Setting) ToUnits(mgdl string) string {
+	value, err := Level(mgdl)
+	if err != nil {
+		return mgdl
+	}
+
+	return fmt.Sprintf(ValueFormat(s.Units, ""), glucose.Value(value, s.Units))
+}
+
+// FromUnits converts a threshold entered in the units into mg/dl to be stored. It's kept to a
+// tenth of a mg/dl so values entered in mmol/l convert back to what was entered. Blank and invalid
+// values are left as they are.
+func FromUnits(value, units string) string {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+
+	return strconv.FormatFloat(math.Round(glucose.MgDl(parsed, units)*10)/10, 'f', -1, 64)
+}
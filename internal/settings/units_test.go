@@ -0,0 +1,59 @@
+package settings
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFromUnits(t *testing.T) {
+	tests := []struct {
+		value, units, want string
+	}{
+		{"3.9", "mmol", "70.3"},
+		{"4.0", "mmol", "72.1"},
+		{"10.0", "mmol", "180.2"},
+		{"70", "mgdl", "70"},
+		{"", "mmol", ""},
+		{"abc", "mmol", "abc"},
+	}
+	for _, tt := range tests {
+		if got := FromUnits(tt.value, tt.units); got != tt.want {
+			t.Errorf("FromUnits(%q, %q) = %q, want %q", tt.value, tt.units, got, tt.want)
+		}
+	}
+}
+
+func TestThresholdRoundTrip(t *testing.T) {
+	mmol := Setting{Units: "mmol", Format: "%.1f"}
+	for tenths := 10; tenths <= 330; tenths++ {
+		entered := fmt.Sprintf("%.1f", float64(tenths)/10)
+		if got := mmol.ToUnits(FromUnits(entered, "mmol")); got != entered {
+			t.Errorf("%s mmol/l is shown as %s once stored", entered, got)
+		}
+	}
+	mgdl := Setting{Units: "mgdl", Format: "%.0f"}
+	for value := 20; value <= 600; value++ {
+		entered := fmt.Sprint(value)
+		if got := mgdl.ToUnits(FromUnits(entered, "mgdl")); got != entered {
+			t.Errorf("%s mg/dl is shown as %s once stored", entered, got)
+		}
+	}
+}
+
+func TestRound(t *testing.T) {
+	setting := Setting{Units: "mmol", Format: "%.1f"}
+	if got := setting.Round(72); got != 4.0 {
+		t.Errorf("Round(72) = %v, want 4", got)
+	}
+	setting.Display.Decimals = "2"
+	if got := setting.Round(72); got != 4.0 {
+		t.Errorf("Round(72) to 2 decimals = %v, want 4", got)
+	}
+	if got := setting.Round(71); got != 3.9 {
+		t.Errorf("Round(71) to 2 decimals = %v, want 3.9", got)
+	}
+	setting.Display.Decimals = "0"
+	if got := setting.Round(9); got != 0.5 {
+		t.Errorf("Round(9) to 0 decimals = %v, want 0.5", got)
+	}
+}
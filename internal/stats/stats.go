@@ -0,0 +1,189 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+const (
+	// HypoLevel is the level in mg/dl readings have to stay below for a hypo event.
+	HypoLevel = 70
+	// HypoDuration is how long readings have to stay below the hypo level for a hypo event.
+	HypoDuration = 15 * time.Minute
+)
+
+// Periods are the periods statistics can be calculated over in the order they are shown.
+var Periods = []Period{
+	{Name: "24h", Duration: 24 * time.Hour},
+	{Name: "7d", Duration: 7 * 24 * time.Hour},
+	{Name: "14d", Duration: 14 * 24 * time.Hour},
+	{Name: "30d", Duration: 30 * 24 * time.Hour},
+	{Name: "90d", Duration: 90 * 24 * time.Hour},
+}
+
+// Period is a period of history leading up to now.
+type Period struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Stats are the glycemic statistics of a set of readings, levels are in mg/dl.
+type Stats struct {
+	Readings int
+	// Below, In and Above are the percentages of readings below, in and above the range.
+	Below float64
+	In    float64
+	Above float64
+	Mean  float64
+	SD    float64
+	// CV is the coefficient of variation as a percentage.
+	CV float64
+	// GMI is the glucose management indicator, the estimated A1c as a percentage.
+	GMI        float64
+	HypoEvents int
+}
+
+// Row is a labelled statistic formatted for display.
+type Row struct {
+	Label string
+	Value string
+}
+
+// ParsePeriod gets the period with the name.
+func ParsePeriod(name string) (Period, bool) {
+	for _, period := range Periods {
+		if period.Name == name {
+			return period, true
+		}
+	}
+
+	return Period{}, false
+}
+
+// Range gets the target range of the settings in mg/dl.
+func Range(setting settings.Setting) (float64, float64, error) {
+	low, err := settings.Level(setting.Range.Low)
+	if err != nil {
+		return 0, 0, err
+	}
+	high, err := settings.Level(setting.Range.High)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return low, high, nil
+}
+
+// Since calculates the statistics of the stored readings from the time until now, history gets
+// the stored readings between two times.
+func Since(from time.Time, setting settings.Setting, history func(from, to time.Time) ([]glucose.Reading, error)) (Stats, error) {
+	low, high, err := Range(setting)
+	if err != nil {
+		return Stats{}, err
+	}
+	readings, err := history(from, time.Now())
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return Calculate(readings, low, high), nil
+}
+
+// Calculate calculates the statistics of the readings against the range, low and high are in mg/dl.
+func Calculate(readings []glucose.Reading, low, high float64) Stats {
+	readings = slices.DeleteFunc(slices.Clone(readings), glucose.Reading.Missing)
+	slices.SortFunc(readings, func(a, b glucose.Reading) int {
+		return a.Time.Compare(b.Time)
+	})
+	var stats Stats
+	stats.Readings = len(readings)
+	if stats.Readings == 0 {
+		return stats
+	}
+
+	var below, above, sum float64
+	for _, reading := range readings {
+		value := float64(reading.MgDl)
+		switch {
+		case value < low:
+			below++
+		case value > high:
+			above++
+		}
+		sum += value
+	}
+	count := float64(stats.Readings)
+	stats.Below = below / count * 100
+	stats.Above = above / count * 100
+	stats.In = 100 - stats.Below - stats.Above
+	stats.Mean = sum / count
+	var squares float64
+	for _, reading := range readings {
+		squares += math.Pow(float64(reading.MgDl)-stats.Mean, 2)
+	}
+	stats.SD = math.Sqrt(squares / count)
+	stats.CV = stats.SD / stats.Mean * 100
+	stats.GMI = 3.31 + 0.02392*stats.Mean
+	stats.HypoEvents = hypoEvents(readings)
+
+	return stats
+}
+
+// hypoEvents counts the times the readings stayed below the hypo level for the hypo duration,
+// an event lasts until the first reading back at or above the level.
+func hypoEvents(readings []glucose.Reading) int {
+	events := 0
+	var start time.Time
+	for _, reading := range readings {
+		if reading.MgDl < HypoLevel {
+			if start.IsZero() {
+				start = reading.Time
+			}
+			continue
+		}
+		if !start.IsZero() && reading.Time.Sub(start) >= HypoDuration {
+			events++
+		}
+		start = time.Time{}
+	}
+	if !start.IsZero() && readings[len(readings)-1].Time.Sub(start) >= HypoDuration {
+		events++
+	}
+
+	return events
+}
+
+// Rows formats the statistics for display in the units of the settings.
+func (s Stats) Rows(setting settings.Setting) []Row {
+	unit := glucose.UnitLabel(setting.Units)
+	value := func(mgdl float64) string {
+		return setting.Number(glucose.Value(mgdl, setting.Units), setting.Units) + " " + unit
+	}
+
+	return []Row{
+		{Label: "Readings", Value: strconv.Itoa(s.Readings)},
+		{Label: "Below range", Value: fmt.Sprintf("%.1f%%", s.Below)},
+		{Label: "In range", Value: fmt.Sprintf("%.1f%%", s.In)},
+		{Label: "Above range", Value: fmt.Sprintf("%.1f%%", s.Above)},
+		{Label: "Mean", Value: value(s.Mean)},
+		{Label: "Standard deviation", Value: value(s.SD)},
+		{Label: "Coefficient of variation", Value: fmt.Sprintf("%.1f%%", s.CV)},
+		{Label: "GMI (eA1c)", Value: fmt.Sprintf("%.1f%%", s.GMI)},
+		{Label: "Hypo events", Value: strconv.Itoa(s.HypoEvents)},
+	}
+}
+
+// InRange summarises the time in range.
+func (s Stats) InRange() string {
+	if s.Readings == 0 {
+		return "no readings"
+	}
+
+	return fmt.Sprintf("%.0f%% in range", s.In)
+}
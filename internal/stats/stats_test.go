@@ -0,0 +1,91 @@
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+func readings(start time.Time, values ...int) []glucose.Reading {
+	var readings []glucose.Reading
+	for i, value := range values {
+		readings = append(readings, glucose.Reading{Time: start.Add(time.Duration(i) * 5 * time.Minute), MgDl: value})
+	}
+
+	return readings
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+func TestCalculate(t *testing.T) {
+	stats := Calculate(readings(time.Now(), 60, 100, 140, 200, 0), 70, 180)
+	if stats.Readings != 4 {
+		t.Errorf("readings = %d, want missing readings skipped", stats.Readings)
+	}
+	if !near(stats.Below, 25) || !near(stats.In, 50) || !near(stats.Above, 25) {
+		t.Errorf("below, in, above = %.1f, %.1f, %.1f", stats.Below, stats.In, stats.Above)
+	}
+	if !near(stats.Mean, 125) {
+		t.Errorf("mean = %.2f, want 125", stats.Mean)
+	}
+	// population standard deviation of 60, 100, 140 and 200
+	if !near(stats.SD, 51.72) || !near(stats.CV, 41.38) {
+		t.Errorf("sd, cv = %.2f, %.2f", stats.SD, stats.CV)
+	}
+	if !near(stats.GMI, 6.30) {
+		t.Errorf("gmi = %.2f, want 6.30", stats.GMI)
+	}
+}
+
+func TestCalculateEmpty(t *testing.T) {
+	stats := Calculate(nil, 70, 180)
+	if stats != (Stats{}) || stats.InRange() != "no readings" {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestHypoEvents(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name   string
+		values []int
+		want   int
+	}{
+		{"too short", []int{100, 65, 65, 100}, 0},
+		{"fifteen minutes", []int{100, 65, 65, 65, 100}, 1},
+		{"two events", []int{65, 65, 65, 100, 60, 60, 60, 60}, 2},
+		{"ongoing", []int{100, 60, 60, 60, 60}, 1},
+	}
+	for _, test := range tests {
+		if got := Calculate(readings(start, test.values...), 70, 180).HypoEvents; got != test.want {
+			t.Errorf("%s: hypo events = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	// the range is stored in mg/dl whatever the units
+	low, high, err := Range(settings.Setting{Units: "mmol", Range: settings.Range{Low: "70.3", High: "180"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if low != 70.3 || high != 180 {
+		t.Errorf("range = %.1f-%.1f", low, high)
+	}
+}
+
+func TestInRange(t *testing.T) {
+	stats := Stats{Readings: 10, In: 78.2}
+	if got := stats.InRange(); got != "78% in range" {
+		t.Errorf("InRange() = %q", got)
+	}
+	rows := stats.Rows(settings.Setting{Units: "mgdl", Format: "%.0f"})
+	if rows[2].Label != "In range" || rows[2].Value != "78.2%" {
+		t.Errorf("rows = %+v", rows)
+	}
+}
@@ -0,0 +1,124 @@
+package status
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+// Details is everything known about a reading, shown in the tray tooltip and on the command line.
+// The change shown is the smoothed rate of the reading.
+type Details struct {
+	Reading glucose.Reading
+	Alerts  []Alert
+}
+
+// Alert is the state of an alert rule.
+type Alert struct {
+	Label string
+	State alert.State
+}
+
+// Format describes the reading over several lines.
+func Format(details Details, setting settings.Setting, now time.Time) string {
+	reading := details.Reading
+	if reading.Missing() {
+		return "No reading"
+	}
+	lines := []string{
+		fmt.Sprintf("%s %s, %s", values(float64(reading.MgDl), setting), glucose.Arrow(reading.Trend), glucose.TrendDescription(reading.Trend)),
+		"Change: " + changes(reading.Rate, setting),
+		fmt.Sprintf("Taken at %s, %s", reading.Time.Local().Format("15:04:05 2 Jan"), ago(reading.Time, now)),
+	}
+	if reading.Source != "" {
+		lines = append(lines, "Source: "+reading.Source)
+	}
+	lines = append(lines, "Alerts: "+alerts(details.Alerts, now))
+
+	return strings.Join(lines, "\n")
+}
+
+// Short describes the reading on a single line, for status bars.
+func Short(details Details, setting settings.Setting, now time.Time) string {
+	reading := details.Reading
+	if reading.Missing() {
+		return "No reading"
+	}
+	change := rateChange(reading.Rate, setting, setting.Units) + setting.Rate.Label()
+	short := fmt.Sprintf("%s %s %s (%s)", setting.Value(float64(reading.MgDl)), glucose.Arrow(reading.Trend), change, ago(reading.Time, now))
+	for _, a := range details.Alerts {
+		if a.State.Active && !a.State.Acknowledged && !a.State.SnoozedUntil.After(now) {
+			short += " " + strings.ToUpper(a.Label)
+		}
+	}
+
+	return short
+}
+
+// values formats a mg/dl value in both units, the selected units first.
+func values(mgdl float64, setting settings.Setting) string {
+	mmol := setting.Number(glucose.Value(mgdl, "mmol"), "mmol") + " mmol/L"
+	mgdlValue := setting.Number(mgdl, "mgdl") + " mg/dL"
+	if setting.Units == "mgdl" {
+		return fmt.Sprintf("%s (%s)", mgdlValue, mmol)
+	}
+
+	return fmt.Sprintf("%s (%s)", mmol, mgdlValue)
+}
+
+// rateChange formats the change in the units over the time the rate is shown for, a rate in mg/dl
+// per minute.
+func rateChange(rate float64, setting settings.Setting, units string) string {
+	return setting.Change(glucose.Value(rate*setting.Rate.Minutes(), units), setting.RateFormat(units))
+}
+
+// changes describes the rate of change in both units, the selected units first.
+func changes(rate float64, setting settings.Setting) string {
+	mmol := rateChange(rate, setting, "mmol") + " mmol/L" + setting.Rate.Label()
+	mgdl := rateChange(rate, setting, "mgdl") + " mg/dL" + setting.Rate.Label()
+	if setting.Units == "mgdl" {
+		return fmt.Sprintf("%s (%s)", mgdl, mmol)
+	}
+
+	return fmt.Sprintf("%s (%s)", mmol, mgdl)
+}
+
+// ago describes how long ago a time was.
+func ago(t time.Time, now time.Time) string {
+	minutes := int(now.Sub(t).Minutes())
+	switch {
+	case minutes < 1:
+		return "just now"
+	case minutes == 1:
+		return "1 min ago"
+	}
+
+	return fmt.Sprintf("%d min ago", minutes)
+}
+
+// alerts describes the active alerts.
+func alerts(states []Alert, now time.Time) string {
+	var active []string
+	for _, a := range states {
+		if !a.State.Active {
+			continue
+		}
+		switch {
+		case a.State.SnoozedUntil.After(now):
+			active = append(active, fmt.Sprintf("%s (snoozed until %s)", a.Label, a.State.SnoozedUntil.Local().Format("15:04")))
+		case a.State.Acknowledged:
+			active = append(active, a.Label+" (acknowledged)")
+		default:
+			active = append(active, a.Label)
+		}
+	}
+	if len(active) == 0 {
+		return "none"
+	}
+
+	return strings.Join(active, ", ")
+}
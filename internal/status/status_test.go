@@ -0,0 +1,74 @@
+package status
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+func TestFormat(t *testing.T) {
+	now := time.Date(2024, 3, 1, 8, 13, 0, 0, time.Local)
+	details := Details{
+		Reading: glucose.Reading{Time: now.Add(-3 * time.Minute), MgDl: 126, Trend: "FORTY_FIVE_UP", Delta: 12, Rate: 1.8, Source: "sugarmate"},
+		Alerts: []Alert{
+			{Label: "High", State: alert.State{Active: true, Acknowledged: true}},
+			{Label: "Low"},
+			{Label: "Fast change", State: alert.State{Active: true, SnoozedUntil: now.Add(time.Hour)}},
+		},
+	}
+	setting := settings.Setting{Units: "mmol", Format: "%.1f", Display: settings.Display{Sign: "true"}}
+	want := "7.0 mmol/L (126 mg/dL) ↗, rising slowly\n" +
+		"Change: +0.5 mmol/L/5min (+9 mg/dL/5min)\n" +
+		"Taken at 08:10:00 1 Mar, 3 min ago\n" +
+		"Source: sugarmate\n" +
+		"Alerts: High (acknowledged), Fast change (snoozed until 09:13)"
+	if got := Format(details, setting, now); got != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", got, want)
+	}
+
+	setting = settings.Setting{Units: "mgdl", Format: "%.0f"}
+	details.Reading.Rate = -0.8
+	details.Alerts = nil
+	want = "126 mg/dL (7.0 mmol/L) ↗, rising slowly\n" +
+		"Change: -4 mg/dL/5min (-0.2 mmol/L/5min)\n" +
+		"Taken at 08:10:00 1 Mar, 3 min ago\n" +
+		"Source: sugarmate\n" +
+		"Alerts: none"
+	if got := Format(details, setting, now); got != want {
+		t.Errorf("Format() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestShort(t *testing.T) {
+	now := time.Date(2024, 3, 1, 8, 13, 0, 0, time.UTC)
+	details := Details{
+		Reading: glucose.Reading{Time: now.Add(-30 * time.Second), MgDl: 63, Trend: "SINGLE_DOWN", Delta: -9, Rate: -1.2},
+		Alerts: []Alert{
+			{Label: "Low", State: alert.State{Active: true}},
+			{Label: "Predicted low", State: alert.State{Active: true, Acknowledged: true}},
+		},
+	}
+	setting := settings.Setting{Units: "mmol", Format: "%.1f"}
+	if got, want := Short(details, setting, now), "3.5 ↓ -0.3/5min (just now) LOW"; got != want {
+		t.Errorf("Short() = %q, want %q", got, want)
+	}
+	setting.Display = settings.Display{Both: "true", Separator: ","}
+	if got, want := Short(details, setting, now), "3,5 | 63 ↓ -0,3/5min (just now) LOW"; got != want {
+		t.Errorf("Short() = %q, want %q", got, want)
+	}
+	setting.Rate.Per = "1"
+	details.Reading.Rate = 1.2
+	if got, want := Short(details, setting, now), "3,5 | 63 ↓ 0,07/min (just now) LOW"; got != want {
+		t.Errorf("Short() = %q, want %q", got, want)
+	}
+	setting.Display.Sign = "true"
+	if got, want := Short(details, setting, now), "3,5 | 63 ↓ +0,07/min (just now) LOW"; got != want {
+		t.Errorf("Short() = %q, want %q", got, want)
+	}
+	if got := Short(Details{}, setting, now); got != "No reading" {
+		t.Errorf("Short() = %q", got)
+	}
+}
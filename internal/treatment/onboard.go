@@ -0,0 +1,132 @@
+package treatment
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+// Curve is an exponential insulin action curve which peaks after Peak and stops acting after
+// Duration, the duration of insulin action.
+type Curve struct {
+	Peak     time.Duration
+	Duration time.Duration
+}
+
+// Lookback is how far back treatments can still be on board.
+const Lookback = 12 * time.Hour
+
+// CurvePeaks are the peaks of the preset insulin action curves.
+var CurvePeaks = map[string]time.Duration{
+	"rapid":       75 * time.Minute,
+	"ultra-rapid": 55 * time.Minute,
+}
+
+// CurveFor gets the insulin action curve of the settings.
+func CurveFor(setting settings.Insulin) (Curve, error) {
+	dia, err := strconv.ParseFloat(setting.DIA, 64)
+	if err != nil {
+		return Curve{}, fmt.Errorf("invalid duration of insulin action %q", setting.DIA)
+	}
+	curve := Curve{Duration: time.Duration(dia * float64(time.Hour))}
+	peak, ok := CurvePeaks[setting.Curve]
+	if !ok {
+		minutes, err := strconv.ParseFloat(setting.Peak, 64)
+		if err != nil {
+			return Curve{}, fmt.Errorf("invalid insulin peak %q", setting.Peak)
+		}
+		peak = time.Duration(minutes * float64(time.Minute))
+	}
+	curve.Peak = peak
+	// the exponential model needs the peak in the first half of the action
+	if curve.Peak <= 0 || curve.Peak*2 >= curve.Duration {
+		return Curve{}, fmt.Errorf("the insulin peak of %s must be less than half the duration of action of %s", curve.Peak, curve.Duration)
+	}
+
+	return curve, nil
+}
+
+// Remaining gets the fraction of a dose of insulin still to act after the elapsed time.
+func (c Curve) Remaining(elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 1
+	}
+	if elapsed >= c.Duration {
+		return 0
+	}
+	t, td, tp := elapsed.Minutes(), c.Duration.Minutes(), c.Peak.Minutes()
+	tau := tp * (1 - tp/td) / (1 - 2*tp/td)
+	a := 2 * tau / td
+	s := 1 / (1 - a + (1+a)*math.Exp(-td/tau))
+
+	return 1 - s*(1-a)*((t*t/(tau*td*(1-a))-t/tau-1)*math.Exp(-t/tau)+1)
+}
+
+// OnBoard is the insulin and carbs still to act.
+type OnBoard struct {
+	// Insulin is in units and Carbs in grams.
+	Insulin float64
+	Carbs   float64
+}
+
+func (o OnBoard) String() string {
+	return fmt.Sprintf("%.1f U insulin, %.0f g carbs on board", o.Insulin, o.Carbs)
+}
+
+// Calculate works out the insulin and carbs on board at a time from the treatments taken up to
+// now, so the insulin and carbs on board later can be projected.
+func Calculate(treatments []Treatment, setting settings.Insulin, now, at time.Time) (OnBoard, error) {
+	var onBoard OnBoard
+	curve, err := CurveFor(setting)
+	if err != nil {
+		return onBoard, err
+	}
+	rate, err := strconv.ParseFloat(setting.CarbRate, 64)
+	if err != nil || rate <= 0 {
+		return onBoard, fmt.Errorf("invalid carb absorption rate %q", setting.CarbRate)
+	}
+	for _, t := range treatments {
+		if t.Time.After(now) {
+			continue
+		}
+		elapsed := at.Sub(t.Time)
+		switch t.Type {
+		case Insulin:
+			onBoard.Insulin += t.Amount * curve.Remaining(elapsed)
+		case Carbs:
+			onBoard.Carbs += max(0, t.Amount-rate*max(0, elapsed.Hours()))
+		}
+	}
+
+	return onBoard, nil
+}
+
+// Effect projects the change in glucose in mg/dl from now until later caused by the insulin and
+// carbs on board, ok is false when the sensitivity isn't set. Carbs are only counted when the
+// carb ratio is set.
+func Effect(treatments []Treatment, setting settings.Insulin, now, later time.Time) (float64, bool, error) {
+	if setting.Sensitivity == "" {
+		return 0, false, nil
+	}
+	sensitivity, err := strconv.ParseFloat(setting.Sensitivity, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid insulin sensitivity %q", setting.Sensitivity)
+	}
+	current, err := Calculate(treatments, setting, now, now)
+	if err != nil {
+		return 0, false, err
+	}
+	projected, err := Calculate(treatments, setting, now, later)
+	if err != nil {
+		return 0, false, err
+	}
+	effect := -sensitivity * (current.Insulin - projected.Insulin)
+	if ratio, err := strconv.ParseFloat(setting.CarbRatio, 64); err == nil && ratio > 0 {
+		effect += sensitivity / ratio * (current.Carbs - projected.Carbs)
+	}
+
+	return effect, true, nil
+}
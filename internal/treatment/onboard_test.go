@@ -0,0 +1,94 @@
+package treatment
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
+
+var insulin = settings.Insulin{Curve: "rapid", DIA: "5", Sensitivity: "45", CarbRatio: "10", CarbRate: "30"}
+
+func TestCurveFor(t *testing.T) {
+	curve, err := CurveFor(settings.Insulin{Curve: "custom", DIA: "4", Peak: "60"})
+	if err != nil || curve.Peak != time.Hour || curve.Duration != 4*time.Hour {
+		t.Errorf("CurveFor(custom) = %+v, %v", curve, err)
+	}
+	curve, err = CurveFor(settings.Insulin{Curve: "ultra-rapid", DIA: "5", Peak: "200"})
+	if err != nil || curve.Peak != 55*time.Minute {
+		t.Errorf("CurveFor(ultra-rapid) = %+v, %v, want the preset peak", curve, err)
+	}
+	for _, setting := range []settings.Insulin{{Curve: "rapid", DIA: ""}, {Curve: "custom", DIA: "3", Peak: "90"}} {
+		if _, err := CurveFor(setting); err == nil {
+			t.Errorf("CurveFor(%+v) = nil, want an error", setting)
+		}
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	curve := Curve{Peak: 75 * time.Minute, Duration: 5 * time.Hour}
+	if curve.Remaining(0) != 1 || curve.Remaining(5*time.Hour) != 0 {
+		t.Error("a dose starts fully on board and is gone after the duration of action")
+	}
+	previous := 1.0
+	for minutes := 5; minutes < 300; minutes += 5 {
+		remaining := curve.Remaining(time.Duration(minutes) * time.Minute)
+		if remaining > previous || remaining < 0 {
+			t.Fatalf("remaining after %d minutes = %.3f, after the previous %.3f", minutes, remaining, previous)
+		}
+		previous = remaining
+	}
+	// about half of a rapid acting dose is left after two hours with a five hour action
+	if remaining := curve.Remaining(2 * time.Hour); remaining < 0.4 || remaining > 0.6 {
+		t.Errorf("remaining after 2 hours = %.3f", remaining)
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	treatments := []Treatment{
+		{Time: now.Add(-6 * time.Hour), Type: Insulin, Amount: 10},
+		{Time: now, Type: Insulin, Amount: 4},
+		{Time: now.Add(-time.Hour), Type: Carbs, Amount: 45},
+		{Time: now.Add(time.Hour), Type: Carbs, Amount: 60},
+		{Time: now, Type: Exercise, Amount: 30},
+	}
+	onBoard, err := Calculate(treatments, insulin, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(onBoard.Insulin, 4) || !near(onBoard.Carbs, 15) {
+		t.Errorf("on board = %+v, want 4 U and 15 g", onBoard)
+	}
+	onBoard, _ = Calculate(treatments, insulin, now, now.Add(time.Hour))
+	if onBoard.Carbs != 0 || onBoard.Insulin >= 4 {
+		t.Errorf("on board in an hour = %+v", onBoard)
+	}
+}
+
+func TestEffect(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	treatments := []Treatment{
+		{Time: now.Add(-time.Hour), Type: Insulin, Amount: 2},
+		{Time: now.Add(-30 * time.Minute), Type: Carbs, Amount: 15},
+	}
+	effect, ok, err := Effect(treatments, insulin, now, now.Add(5*time.Hour))
+	if err != nil || !ok {
+		t.Fatal(ok, err)
+	}
+	onBoard, _ := Calculate(treatments, insulin, now, now)
+	// every unit on board drops 45 mg/dl and every gram raises 4.5 mg/dl by the end
+	want := -45*onBoard.Insulin + 4.5*onBoard.Carbs
+	if !near(effect, want) {
+		t.Errorf("effect = %.2f, want %.2f", effect, want)
+	}
+	_, ok, _ = Effect(treatments, settings.Insulin{Curve: "rapid", DIA: "5", CarbRate: "30"}, now, now.Add(time.Hour))
+	if ok {
+		t.Error("effect without a sensitivity should not be ok")
+	}
+}
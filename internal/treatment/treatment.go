@@ -0,0 +1,84 @@
+package treatment
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// The types of treatment which can be logged.
+const (
+	Insulin  = "insulin"
+	Carbs    = "carbs"
+	Exercise = "exercise"
+	Note     = "note"
+)
+
+// Types are the types of treatment which can be logged from the dashboard.
+var Types = []string{Insulin, Carbs, Exercise, Note}
+
+// Source is the source of treatments logged from the dashboard.
+const Source = "local"
+
+// Treatment is an event which explains changes in glucose, such as insulin taken or carbs eaten.
+type Treatment struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	// Amount is the units of insulin, grams of carbs or minutes of exercise.
+	Amount float64 `json:"amount"`
+	Note   string  `json:"note"`
+	Source string  `json:"source"`
+}
+
+// Unit gets the unit the amount of a type of treatment is measured in.
+func Unit(kind string) string {
+	switch kind {
+	case Insulin:
+		return "U"
+	case Carbs:
+		return "g"
+	case Exercise:
+		return "min"
+	}
+
+	return ""
+}
+
+// Label describes the treatment, as in 4 U insulin.
+func (t Treatment) Label() string {
+	if t.Amount == 0 {
+		if t.Note != "" {
+			return t.Note
+		}
+		return t.Type
+	}
+	label := fmt.Sprintf("%s %s %s", strconv.FormatFloat(t.Amount, 'f', -1, 64), Unit(t.Type), t.Type)
+	if t.Note != "" {
+		label += ": " + t.Note
+	}
+
+	return label
+}
+
+// Validate checks a treatment logged from the dashboard.
+func (t Treatment) Validate() error {
+	if !slices.Contains(Types, t.Type) {
+		return fmt.Errorf("unknown treatment type %q", t.Type)
+	}
+	if t.Time.IsZero() {
+		return errors.New("the treatment needs a time")
+	}
+	if t.Type == Note {
+		if t.Note == "" {
+			return errors.New("the note is empty")
+		}
+		return nil
+	}
+	if t.Amount <= 0 {
+		return fmt.Errorf("the %s amount must be more than 0", t.Type)
+	}
+
+	return nil
+}
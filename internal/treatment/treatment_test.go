@@ -0,0 +1,48 @@
+package treatment
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		treatment Treatment
+		want      string
+	}{
+		{Treatment{Type: Insulin, Amount: 4.5}, "4.5 U insulin"},
+		{Treatment{Type: Carbs, Amount: 30, Note: "toast"}, "30 g carbs: toast"},
+		{Treatment{Type: Exercise, Amount: 45}, "45 min exercise"},
+		{Treatment{Type: Note, Note: "sensor changed"}, "sensor changed"},
+		{Treatment{Type: "site_change"}, "site_change"},
+	}
+	for _, tt := range tests {
+		if got := tt.treatment.Label(); got != tt.want {
+			t.Errorf("Label() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+	valid := []Treatment{
+		{Time: now, Type: Insulin, Amount: 2},
+		{Time: now, Type: Note, Note: "stressed"},
+	}
+	for _, treatment := range valid {
+		if err := treatment.Validate(); err != nil {
+			t.Errorf("Validate(%+v) = %v", treatment, err)
+		}
+	}
+	invalid := []Treatment{
+		{Time: now, Type: "bolus", Amount: 2},
+		{Type: Insulin, Amount: 2},
+		{Time: now, Type: Carbs},
+		{Time: now, Type: Note},
+	}
+	for _, treatment := range invalid {
+		if err := treatment.Validate(); err == nil {
+			t.Errorf("Validate(%+v) = nil, want an error", treatment)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package ui
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/notify"
+)
+
+// alertHistory is the data of the alert history page.
+type alertHistory struct {
+	Rules   []alert.Rule
+	Rule    string
+	From    string
+	To      string
+	Records []alertRow
+	Days    []alert.DayCount
+}
+
+// alertRow is an alert record formatted for the alert history page.
+type alertRow struct {
+	Label    string
+	Message  string
+	Value    string
+	Time     string
+	Status   string
+	Duration string
+}
+
+func handleAlerts(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	history := alertHistory{
+		Rules: alert.Rules,
+		Rule:  req.URL.Query().Get("rule"),
+		From:  req.URL.Query().Get("from"),
+		To:    req.URL.Query().Get("to"),
+	}
+	from, err := time.ParseInLocation(time.DateOnly, history.From, time.Local)
+	if err != nil {
+		from = now.AddDate(0, 0, -7)
+		history.From = from.Format(time.DateOnly)
+	}
+	to, err := time.ParseInLocation(time.DateOnly, history.To, time.Local)
+	if err != nil {
+		to = now
+		history.To = to.Format(time.DateOnly)
+	}
+	records, err := database.GetAlerts(startOfDay(from), startOfDay(to).AddDate(0, 0, 1).Add(-time.Nanosecond))
+	if err != nil {
+		notify.Warning("ERROR!", err.Error())
+		log.Println("error:")
+		log.Println(err)
+	}
+	if history.Rule != "" {
+		records = slices.DeleteFunc(records, func(record alert.Record) bool {
+			return record.Rule != history.Rule
+		})
+	}
+	history.Days = alert.CountByDay(records, time.Local)
+	slices.Reverse(records)
+	setting := Settings()
+	for _, record := range records {
+		history.Records = append(history.Records, alertRow{
+			Label:    ruleLabel(record.Rule),
+			Message:  record.Message,
+			Value:    setting.Value(float64(record.MgDl)),
+			Time:     record.Time.Local().Format(time.DateTime),
+			Status:   alertStatus(record),
+			Duration: record.Duration(now).Round(time.Minute).String(),
+		})
+	}
+
+	t, err := template.New("alerts").Parse(alertsTmpl + layoutTmpl)
+	if err != nil {
+		notify.Warning("ERROR!", err.Error())
+		log.Println("error:")
+		log.Println(err)
+		return
+	}
+	t.Execute(w, history)
+}
+
+// alertStatus describes how an alert was handled.
+func alertStatus(record alert.Record) string {
+	switch {
+	case !record.Acknowledged.IsZero():
+		return "Acknowledged at " + record.Acknowledged.Local().Format(time.TimeOnly)
+	case !record.SnoozedUntil.IsZero():
+		return "Snoozed until " + record.SnoozedUntil.Local().Format(time.TimeOnly)
+	case record.Cleared.IsZero():
+		return "Active"
+	}
+
+	return "Cleared"
+}
+
+// ruleLabel gets the label of a rule.
+func ruleLabel(name string) string {
+	for _, rule := range alert.Rules {
+		if rule.Name == name {
+			return rule.Label
+		}
+	}
+
+	return name
+}
+
+// startOfDay gets midnight on the day of the time.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,129 @@
+package ui
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+)
+
+const (
+	chartWidth  = 1000
+	chartHeight = 300
+	// chartMaxMgDl is the highest level shown on the chart.
+	chartMaxMgDl = 400
+	// chartLeft and chartTop leave room for the level labels and the treatment markers.
+	chartLeft = 50
+	chartTop  = 30
+)
+
+// markerColours are the colours of the treatment markers by type.
+var markerColours = map[string]string{
+	treatment.Insulin:  "#1e88e5",
+	treatment.Carbs:    "#fb8c00",
+	treatment.Exercise: "#43a047",
+}
+
+// chart is the glucose chart of the dashboard, laid out in SVG coordinates.
+type chart struct {
+	Width, Height    float64
+	Left, Right      float64
+	Top, Bottom      float64
+	PlotWidth        float64
+	CenterX, CenterY float64
+	// RangeTop and RangeHeight place the target range band.
+	RangeTop    float64
+	RangeHeight float64
+	// LabelX is where the level labels end.
+	LabelX float64
+	Levels []chartLabel
+	Hours  []chartLabel
+	// Lines are the polyline points of each run of readings without a gap.
+	Lines     []string
+	HasPoints bool
+	Markers   []chartMarker
+}
+
+// chartLabel is an axis label and the position of its grid line.
+type chartLabel struct {
+	Position float64
+	Label    string
+}
+
+// chartMarker is a treatment marked on the chart.
+type chartMarker struct {
+	X      float64
+	Y      float64
+	Colour string
+	Label  string
+	Time   string
+}
+
+// buildChart lays out the readings and treatments between from and to.
+func buildChart(readings []glucose.Reading, treatments []treatment.Treatment, from, to time.Time, low, high float64, setting settings.Setting) chart {
+	c := chart{
+		Width:  chartWidth,
+		Height: chartHeight,
+		Left:   chartLeft,
+		Right:  chartWidth - 10,
+		Top:    chartTop,
+		Bottom: chartHeight - 25,
+	}
+	x := func(t time.Time) float64 {
+		return c.Left + (c.Right-c.Left)*t.Sub(from).Seconds()/to.Sub(from).Seconds()
+	}
+	y := func(mgdl float64) float64 {
+		return c.Bottom - (c.Bottom-c.Top)*math.Min(mgdl, chartMaxMgDl)/chartMaxMgDl
+	}
+	c.PlotWidth = c.Right - c.Left
+	c.RangeTop, c.RangeHeight = y(high), y(low)-y(high)
+	c.LabelX = c.Left - 8
+	c.CenterX, c.CenterY = c.Width/2, c.Height/2
+	for _, level := range []float64{low, high, 250, 350} {
+		c.Levels = append(c.Levels, chartLabel{y(level), setting.Number(glucose.Value(level, setting.Units), setting.Units)})
+	}
+	for hour := from.Truncate(time.Hour).Add(time.Hour); hour.Before(to); hour = hour.Add(time.Hour) {
+		if hour.Local().Hour()%3 == 0 {
+			c.Hours = append(c.Hours, chartLabel{x(hour), hour.Local().Format("15:04")})
+		}
+	}
+
+	var points []string
+	var previous time.Time
+	for _, reading := range readings {
+		if reading.Missing() {
+			continue
+		}
+		// a gap of more than a few missed readings breaks the line
+		if !previous.IsZero() && reading.Time.Sub(previous) > 20*time.Minute && len(points) > 0 {
+			c.Lines = append(c.Lines, strings.Join(points, " "))
+			points = nil
+		}
+		points = append(points, fmt.Sprintf("%.1f,%.1f", x(reading.Time), y(float64(reading.MgDl))))
+		previous = reading.Time
+		c.HasPoints = true
+	}
+	if len(points) > 0 {
+		c.Lines = append(c.Lines, strings.Join(points, " "))
+	}
+
+	for _, t := range treatments {
+		colour, ok := markerColours[t.Type]
+		if !ok {
+			colour = "#757575"
+		}
+		c.Markers = append(c.Markers, chartMarker{
+			X:      x(t.Time),
+			Y:      c.Top - 12,
+			Colour: colour,
+			Label:  t.Label(),
+			Time:   t.Time.Local().Format("15:04"),
+		})
+	}
+
+	return c
+}
@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+)
+
+func TestBuildChart(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	readings := []glucose.Reading{
+		{Time: from, MgDl: 0},
+		{Time: from.Add(time.Hour), MgDl: 100},
+		{Time: from.Add(time.Hour + 5*time.Minute), MgDl: 110},
+		// more than 20 minutes later starts a new line
+		{Time: from.Add(2 * time.Hour), MgDl: 500},
+	}
+	treatments := []treatment.Treatment{
+		{Time: from.Add(12 * time.Hour), Type: treatment.Insulin, Amount: 4},
+		{Time: from.Add(13 * time.Hour), Type: treatment.Note, Note: "walk"},
+	}
+	setting := settings.Setting{Units: "mgdl", Format: "%.0f"}
+	c := buildChart(readings, treatments, from, to, 72, 180, setting)
+
+	if !c.HasPoints || len(c.Lines) != 2 {
+		t.Fatalf("expected 2 lines, got %q", c.Lines)
+	}
+	if len(strings.Fields(c.Lines[0])) != 2 {
+		t.Errorf("expected the missing reading to be skipped, got %q", c.Lines[0])
+	}
+	// readings above the chart are drawn at the top
+	if c.Lines[1] != "128.3,30.0" {
+		t.Errorf("expected the high reading at the top of the chart, got %q", c.Lines[1])
+	}
+	if c.Levels[0].Label != "72" || c.Levels[1].Label != "180" {
+		t.Errorf("expected the range levels, got %+v", c.Levels)
+	}
+	if c.RangeHeight <= 0 || c.RangeTop <= c.Top {
+		t.Errorf("expected the range band inside the chart, got %v, %v", c.RangeTop, c.RangeHeight)
+	}
+	if len(c.Markers) != 2 || c.Markers[0].Colour != markerColours[treatment.Insulin] || c.Markers[1].Colour != "#757575" {
+		t.Errorf("expected an insulin and a note marker, got %+v", c.Markers)
+	}
+	if c.Markers[0].X != c.Left+c.PlotWidth/2 || c.Markers[0].Label != "4 U insulin" {
+		t.Errorf("expected the insulin marker in the middle of the chart, got %+v", c.Markers[0])
+	}
+}
+
+func TestBuildChartEmpty(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	c := buildChart(nil, nil, from, from.Add(24*time.Hour), 72, 180, settings.Setting{Units: "mmol", Format: "%.1f"})
+	if c.HasPoints || len(c.Lines) != 0 || len(c.Markers) != 0 {
+		t.Errorf("expected an empty chart, got %+v", c)
+	}
+	if c.Levels[0].Label != "4.0" {
+		t.Errorf("expected the levels in mmol/l, got %+v", c.Levels)
+	}
+}
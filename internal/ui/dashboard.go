@@ -0,0 +1,157 @@
+package ui
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/export"
+	"github.com/brettcodling/SugarMateReader/internal/notify"
+	"github.com/brettcodling/SugarMateReader/internal/stats"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+)
+
+// dashboard is the data of the dashboard page.
+type dashboard struct {
+	Periods []stats.Period
+	Period  string
+	Stats   []stats.Row
+	Formats []string
+	Units   string
+	From    string
+	To      string
+	Chart   chart
+	Types   []string
+	Now     string
+	OnBoard string
+}
+
+func handleDashboard(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != "/" {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	period, ok := stats.ParsePeriod(req.URL.Query().Get("period"))
+	if !ok {
+		period, _ = stats.ParsePeriod("14d")
+	}
+	setting := Settings()
+	data := dashboard{
+		Periods: stats.Periods,
+		Period:  period.Name,
+		Formats: export.Formats,
+		Units:   setting.Units,
+	}
+	from, to, _ := export.Dates("", "", time.Now())
+	data.From = from.Format(time.DateOnly)
+	data.To = to.Format(time.DateOnly)
+	calculated, err := stats.Since(time.Now().Add(-period.Duration), setting, database.GetReadings)
+	if err != nil {
+		notify.Warning("ERROR!", err.Error())
+		log.Println("error:")
+		log.Println(err)
+	}
+	data.Stats = calculated.Rows(setting)
+	data.Chart, err = dayChart(time.Now())
+	if err != nil {
+		notify.Warning("ERROR!", err.Error())
+		log.Println("error:")
+		log.Println(err)
+	}
+	data.Types = treatment.Types
+	data.OnBoard = OnBoard(time.Now())
+	data.Now = time.Now().Format("2006-01-02T15:04")
+
+	t, err := template.New("dashboard").Parse(dashboardTmpl + layoutTmpl)
+	if err != nil {
+		notify.Warning("ERROR!", err.Error())
+		log.Println("error:")
+		log.Println(err)
+		return
+	}
+	t.Execute(w, data)
+}
+
+// dayChart builds the chart of the readings and treatments over the day up to now.
+func dayChart(now time.Time) (chart, error) {
+	from := now.Add(-24 * time.Hour)
+	setting := Settings()
+	low, high, err := stats.Range(setting)
+	if err != nil {
+		return chart{}, err
+	}
+	readings, err := database.GetReadings(from, now)
+	if err != nil {
+		return chart{}, err
+	}
+	treatments, err := database.GetTreatments(from, now)
+	if err != nil {
+		return chart{}, err
+	}
+
+	return buildChart(readings, treatments, from, now, low, high, setting), nil
+}
+
+// handleTreatments logs a treatment posted from the dashboard form.
+func handleTreatments(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	req.ParseForm()
+	t := treatment.Treatment{
+		Type:   req.PostForm.Get("treatment_type"),
+		Note:   strings.TrimSpace(req.PostForm.Get("treatment_note")),
+		Source: treatment.Source,
+	}
+	t.Time, _ = time.ParseInLocation("2006-01-02T15:04", req.PostForm.Get("treatment_time"), time.Local)
+	if amount := req.PostForm.Get("treatment_amount"); amount != "" {
+		var err error
+		t.Amount, err = strconv.ParseFloat(amount, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid amount %q", amount), http.StatusBadRequest)
+			return
+		}
+	}
+	err := t.Validate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = database.SaveTreatments([]treatment.Treatment{t})
+	if err != nil {
+		notify.Warning("ERROR!", err.Error())
+		log.Println("error:")
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, req, "/", http.StatusSeeOther)
+}
+
+// OnBoard describes the insulin and carbs on board now.
+func OnBoard(now time.Time) string {
+	treatments, err := database.GetTreatments(now.Add(-treatment.Lookback), now)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		return "Insulin and carbs on board unavailable"
+	}
+	onBoard, err := treatment.Calculate(treatments, Settings().Insulin, now, now)
+	if err != nil {
+		return "Insulin and carbs on board unavailable: " + err.Error()
+	}
+
+	return onBoard.String()
+}
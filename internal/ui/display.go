@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"net/http"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+// loadDisplay loads how glucose values are displayed.
+func loadDisplay() settings.Display {
+	display := settings.Display{
+		Both:      database.Get("DISPLAY_BOTH"),
+		Decimals:  database.Get("DISPLAY_DECIMALS"),
+		Sign:      database.Get("DISPLAY_SIGN"),
+		Separator: database.Get("DISPLAY_SEPARATOR"),
+	}
+	if display.Separator == "" {
+		display.Separator = "."
+	}
+
+	return display
+}
+
+// saveDisplay saves how glucose values are displayed, posted from the settings form. Blank decimals
+// use the default precision of the units.
+func saveDisplay(req *http.Request) settings.Display {
+	display := settings.Display{
+		Both:      req.PostForm.Get("display_both"),
+		Decimals:  req.PostForm.Get("display_decimals"),
+		Sign:      req.PostForm.Get("display_sign"),
+		Separator: req.PostForm.Get("display_separator"),
+	}
+	database.Set("DISPLAY_BOTH", display.Both)
+	database.Set("DISPLAY_DECIMALS", display.Decimals)
+	database.Set("DISPLAY_SIGN", display.Sign)
+	database.Set("DISPLAY_SEPARATOR", display.Separator)
+
+	return display
+}
@@ -0,0 +1,62 @@
+package ui
+
+import (
+	"net/http"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	keyring "github.com/zalando/go-keyring"
+)
+
+// smtpKeyring is the keyring service the SMTP password is stored under.
+const smtpKeyring = "SugarMateReader SMTP"
+
+// loadEmail loads the email settings, the SMTP password is kept in the keyring.
+func loadEmail() settings.Email {
+	email := settings.Email{
+		Enabled:  database.Get("EMAIL_ENABLED"),
+		Host:     database.Get("SMTP_HOST"),
+		Port:     database.Get("SMTP_PORT"),
+		Username: database.Get("SMTP_USERNAME"),
+		From:     database.Get("EMAIL_FROM"),
+		To:       database.Get("EMAIL_TO"),
+		Interval: database.Get("EMAIL_INTERVAL"),
+	}
+	if email.Port == "" {
+		email.Port = "587"
+	}
+	if email.Interval == "" {
+		email.Interval = "30"
+	}
+	if email.Username != "" {
+		email.Password, _ = keyring.Get(smtpKeyring, email.Username)
+	}
+
+	return email
+}
+
+// saveEmail saves the email settings posted from the settings form.
+func saveEmail(req *http.Request) settings.Email {
+	email := settings.Email{
+		Enabled:  "false",
+		Host:     req.PostForm.Get("smtp_host"),
+		Port:     req.PostForm.Get("smtp_port"),
+		Username: req.PostForm.Get("smtp_username"),
+		From:     req.PostForm.Get("email_from"),
+		To:       req.PostForm.Get("email_to"),
+		Interval: req.PostForm.Get("email_interval"),
+	}
+	if req.PostForm.Has("email_enabled") {
+		email.Enabled = "true"
+	}
+	database.Set("EMAIL_ENABLED", email.Enabled)
+	database.Set("SMTP_HOST", email.Host)
+	database.Set("SMTP_PORT", email.Port)
+	database.Set("SMTP_USERNAME", email.Username)
+	database.Set("EMAIL_FROM", email.From)
+	database.Set("EMAIL_TO", email.To)
+	database.Set("EMAIL_INTERVAL", email.Interval)
+	email.Password = saveSecret(smtpKeyring, email.Username, req.PostForm.Get("smtp_password"))
+
+	return email
+}
@@ -0,0 +1,61 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/export"
+)
+
+// handleExport downloads the stored readings and treatments between the from and to dates in the format and units.
+func handleExport(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := req.URL.Query()
+	from, to, err := export.Dates(query.Get("from"), query.Get("to"), time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if !slices.Contains(export.Formats, format) {
+		http.Error(w, fmt.Sprintf("unknown export format %q", format), http.StatusBadRequest)
+		return
+	}
+	units := query.Get("units")
+	if units != "mgdl" && units != "mmol" {
+		units = Settings().Units
+	}
+	readings, err := database.GetReadings(from, to)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	treatments, err := database.GetTreatments(from, to)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"readings-%s-%s.%s\"", from.Format(time.DateOnly), to.Format(time.DateOnly), format))
+	err = export.Write(w, format, readings, treatments, units, time.Local)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+	}
+}
@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"net/http"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	keyring "github.com/zalando/go-keyring"
+)
+
+// influxKeyring is the keyring service the InfluxDB token is stored under.
+const influxKeyring = "SugarMateReader InfluxDB"
+
+// loadInflux loads the InfluxDB settings, the token is kept in the keyring.
+func loadInflux() settings.Influx {
+	influx := settings.Influx{
+		Enabled: database.Get("INFLUX_ENABLED"),
+		URL:     database.Get("INFLUX_URL"),
+		Org:     database.Get("INFLUX_ORG"),
+		Bucket:  database.Get("INFLUX_BUCKET"),
+		File:    database.Get("INFLUX_FILE"),
+	}
+	if influx.URL != "" {
+		influx.Token, _ = keyring.Get(influxKeyring, influx.URL)
+	}
+
+	return influx
+}
+
+// saveInflux saves the InfluxDB settings posted from the settings form.
+func saveInflux(req *http.Request) settings.Influx {
+	influx := settings.Influx{
+		Enabled: "false",
+		URL:     req.PostForm.Get("influx_url"),
+		Org:     req.PostForm.Get("influx_org"),
+		Bucket:  req.PostForm.Get("influx_bucket"),
+		File:    req.PostForm.Get("influx_file"),
+	}
+	if req.PostForm.Has("influx_enabled") {
+		influx.Enabled = "true"
+	}
+	database.Set("INFLUX_ENABLED", influx.Enabled)
+	database.Set("INFLUX_URL", influx.URL)
+	database.Set("INFLUX_ORG", influx.Org)
+	database.Set("INFLUX_BUCKET", influx.Bucket)
+	database.Set("INFLUX_FILE", influx.File)
+	influx.Token = saveSecret(influxKeyring, influx.URL, req.PostForm.Get("influx_token"))
+
+	return influx
+}
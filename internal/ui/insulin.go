@@ -0,0 +1,89 @@
+package ui
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/notify"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
+)
+
+// defaultInsulin is the insulin action and carb absorption used when they aren't set or are
+// invalid.
+var defaultInsulin = settings.Insulin{
+	Curve:    "rapid",
+	DIA:      "5",
+	Peak:     "75",
+	CarbRate: "30",
+}
+
+// loadInsulin loads the insulin action and carb absorption settings.
+func loadInsulin() settings.Insulin {
+	insulin, err := validInsulin(settings.Insulin{
+		Curve:       database.Get("INSULIN_CURVE"),
+		DIA:         database.Get("INSULIN_DIA"),
+		Peak:        database.Get("INSULIN_PEAK"),
+		Sensitivity: database.Get("INSULIN_SENSITIVITY"),
+		CarbRatio:   database.Get("CARB_RATIO"),
+		CarbRate:    database.Get("CARB_RATE"),
+	})
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+	}
+
+	return insulin
+}
+
+// saveInsulin saves the insulin action and carb absorption settings posted from the settings form,
+// the sensitivity is posted in the units.
+func saveInsulin(req *http.Request, units string) settings.Insulin {
+	insulin, err := validInsulin(settings.Insulin{
+		Curve:       req.PostForm.Get("insulin_curve"),
+		DIA:         req.PostForm.Get("insulin_dia"),
+		Peak:        req.PostForm.Get("insulin_peak"),
+		Sensitivity: settings.FromUnits(req.PostForm.Get("insulin_sensitivity"), units),
+		CarbRatio:   req.PostForm.Get("carb_ratio"),
+		CarbRate:    req.PostForm.Get("carb_rate"),
+	})
+	if err != nil {
+		notify.Warning("ERROR!", err.Error())
+	}
+	database.Set("INSULIN_CURVE", insulin.Curve)
+	database.Set("INSULIN_DIA", insulin.DIA)
+	database.Set("INSULIN_PEAK", insulin.Peak)
+	database.Set("INSULIN_SENSITIVITY", insulin.Sensitivity)
+	database.Set("CARB_RATIO", insulin.CarbRatio)
+	database.Set("CARB_RATE", insulin.CarbRate)
+
+	return insulin
+}
+
+// validInsulin fills in blank settings with the defaults. An insulin action curve which can't be
+// used is replaced by the default curve and a carb absorption rate which isn't positive by the
+// default rate, returning the error so it can be shown.
+func validInsulin(insulin settings.Insulin) (settings.Insulin, error) {
+	if insulin.Curve == "" {
+		insulin.Curve = defaultInsulin.Curve
+	}
+	if insulin.DIA == "" {
+		insulin.DIA = defaultInsulin.DIA
+	}
+	if insulin.Peak == "" {
+		insulin.Peak = defaultInsulin.Peak
+	}
+	if rate, err := strconv.ParseFloat(insulin.CarbRate, 64); err != nil || rate <= 0 {
+		insulin.CarbRate = defaultInsulin.CarbRate
+	}
+	_, err := treatment.CurveFor(insulin)
+	if err != nil {
+		insulin.Curve = defaultInsulin.Curve
+		insulin.DIA = defaultInsulin.DIA
+		insulin.Peak = defaultInsulin.Peak
+	}
+
+	return insulin, err
+}
@@ -0,0 +1,59 @@
+package ui
+
+import (
+	"net/http"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	keyring "github.com/zalando/go-keyring"
+)
+
+// mqttKeyring is the keyring service the broker password is stored under.
+const mqttKeyring = "SugarMateReader MQTT"
+
+// loadMQTT loads the MQTT broker settings, the password is kept in the keyring.
+func loadMQTT() settings.MQTT {
+	mqtt := settings.MQTT{
+		Enabled:   database.Get("MQTT_ENABLED"),
+		Broker:    database.Get("MQTT_BROKER"),
+		Username:  database.Get("MQTT_USERNAME"),
+		Topic:     database.Get("MQTT_TOPIC"),
+		Discovery: database.Get("MQTT_DISCOVERY"),
+	}
+	if mqtt.Topic == "" {
+		mqtt.Topic = "sugarmatereader"
+	}
+	if mqtt.Discovery == "" {
+		mqtt.Discovery = "true"
+	}
+	if mqtt.Username != "" {
+		mqtt.Password, _ = keyring.Get(mqttKeyring, mqtt.Username)
+	}
+
+	return mqtt
+}
+
+// saveMQTT saves the MQTT broker settings posted from the settings form.
+func saveMQTT(req *http.Request) settings.MQTT {
+	mqtt := settings.MQTT{
+		Enabled:   "false",
+		Broker:    req.PostForm.Get("mqtt_broker"),
+		Username:  req.PostForm.Get("mqtt_username"),
+		Topic:     req.PostForm.Get("mqtt_topic"),
+		Discovery: "false",
+	}
+	if req.PostForm.Has("mqtt_enabled") {
+		mqtt.Enabled = "true"
+	}
+	if req.PostForm.Has("mqtt_discovery") {
+		mqtt.Discovery = "true"
+	}
+	database.Set("MQTT_ENABLED", mqtt.Enabled)
+	database.Set("MQTT_BROKER", mqtt.Broker)
+	database.Set("MQTT_USERNAME", mqtt.Username)
+	database.Set("MQTT_TOPIC", mqtt.Topic)
+	database.Set("MQTT_DISCOVERY", mqtt.Discovery)
+	mqtt.Password = saveSecret(mqttKeyring, mqtt.Username, req.PostForm.Get("mqtt_password"))
+
+	return mqtt
+}
@@ -0,0 +1,42 @@
+package ui
+
+import (
+	"net/http"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+// loadPoll loads when readings are fetched.
+func loadPoll() settings.Poll {
+	poll := settings.Poll{
+		Cadence: database.Get("POLL_CADENCE"),
+		Delay:   database.Get("POLL_DELAY"),
+		Retry:   database.Get("POLL_RETRY"),
+	}
+	if poll.Cadence == "" {
+		poll.Cadence = "auto"
+	}
+	if poll.Delay == "" {
+		poll.Delay = "10"
+	}
+	if poll.Retry == "" {
+		poll.Retry = "30"
+	}
+
+	return poll
+}
+
+// savePoll saves when readings are fetched, posted from the settings form.
+func savePoll(req *http.Request) settings.Poll {
+	poll := settings.Poll{
+		Cadence: req.PostForm.Get("poll_cadence"),
+		Delay:   req.PostForm.Get("poll_delay"),
+		Retry:   req.PostForm.Get("poll_retry"),
+	}
+	database.Set("POLL_CADENCE", poll.Cadence)
+	database.Set("POLL_DELAY", poll.Delay)
+	database.Set("POLL_RETRY", poll.Retry)
+
+	return poll
+}
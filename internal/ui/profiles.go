@@ -0,0 +1,170 @@
+package ui
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+// alertKeys maps the alert form fields to the database keys they are stored under.
+var alertKeys = []struct {
+	field string
+	key   string
+	value func(alert *settings.Alert) *string
+}{
+	{"alert_low_enabled", "LOW_ALERT_ENABLED", func(a *settings.Alert) *string { return &a.LowEnabled }},
+	{"alert_low", "LOW_ALERT", func(a *settings.Alert) *string { return &a.Low }},
+	{"alert_low_repeat", "LOW_ALERT_REPEAT", func(a *settings.Alert) *string { return &a.LowRepeat }},
+	{"alert_high_enabled", "HIGH_ALERT_ENABLED", func(a *settings.Alert) *string { return &a.HighEnabled }},
+	{"alert_high", "HIGH_ALERT", func(a *settings.Alert) *string { return &a.High }},
+	{"alert_high_repeat", "HIGH_ALERT_REPEAT", func(a *settings.Alert) *string { return &a.HighRepeat }},
+	{"fast_change_enabled", "FAST_CHANGE_ENABLED", func(a *settings.Alert) *string { return &a.FastChangeEnabled }},
+	{"fast_change", "FAST_CHANGE", func(a *settings.Alert) *string { return &a.FastChange }},
+	{"fast_change_repeat", "FAST_CHANGE_REPEAT", func(a *settings.Alert) *string { return &a.FastChangeRepeat }},
+	{"predictive_low_enabled", "PREDICTIVE_LOW_ENABLED", func(a *settings.Alert) *string { return &a.PredictiveLowEnabled }},
+	{"predictive_low", "PREDICTIVE_LOW", func(a *settings.Alert) *string { return &a.PredictiveLow }},
+	{"escalate_enabled", "ESCALATE_ENABLED", func(a *settings.Alert) *string { return &a.Escalate }},
+	{"escalate_sound", "ESCALATE_SOUND", func(a *settings.Alert) *string { return &a.EscalateSound }},
+	{"escalate_critical", "ESCALATE_CRITICAL", func(a *settings.Alert) *string { return &a.EscalateCritical }},
+}
+
+// glucoseAlertKeys are the keys of the alert settings which are glucose levels, stored in mg/dl.
+var glucoseAlertKeys = []string{"LOW_ALERT", "HIGH_ALERT", "FAST_CHANGE"}
+
+// profileKeyPrefix gets the prefix of the database keys for a profile, the day profile uses the
+// keys which were used before profiles existed.
+func profileKeyPrefix(name string) string {
+	if name == "day" {
+		return ""
+	}
+
+	return strings.ToUpper(name) + "_"
+}
+
+// defaultAlert gets the default alert settings of a profile.
+func defaultAlert(name string) settings.Alert {
+	alert := settings.Alert{
+		Low:              "72",
+		LowRepeat:        "5",
+		High:             "216",
+		HighRepeat:       "0",
+		FastChange:       "9",
+		FastChangeRepeat: "5",
+		PredictiveLow:    "20",
+		EscalateSound:    "5",
+		EscalateCritical: "10",
+	}
+	switch name {
+	case "night":
+		// fast changes are too noisy overnight but lows need to wake someone up
+		alert.LowEnabled = "true"
+		alert.LowRepeat = "5"
+		alert.FastChangeEnabled = "false"
+		alert.PredictiveLowEnabled = "true"
+		alert.HighRepeat = "60"
+		alert.Escalate = "true"
+	case "exercise":
+		alert.LowEnabled = "true"
+		alert.Low = "90"
+		alert.PredictiveLowEnabled = "true"
+		alert.PredictiveLow = "30"
+	}
+
+	return alert
+}
+
+// loadAlert loads the alert settings of a profile.
+func loadAlert(name string) settings.Alert {
+	alert := defaultAlert(name)
+	prefix := profileKeyPrefix(name)
+	for _, key := range alertKeys {
+		if value := database.Get(prefix + key.key); value != "" {
+			*key.value(&alert) = value
+		}
+	}
+
+	return alert
+}
+
+// saveAlert saves the alert settings of a profile posted from the settings form, glucose levels are
+// posted in the units.
+func saveAlert(req *http.Request, name, units string) settings.Alert {
+	var alert settings.Alert
+	prefix := profileKeyPrefix(name)
+	for _, key := range alertKeys {
+		value := req.PostForm.Get(name + "_" + key.field)
+		if strings.HasSuffix(key.field, "_enabled") {
+			value = "false"
+			if req.PostForm.Has(name + "_" + key.field) {
+				value = "true"
+			}
+		}
+		if slices.Contains(glucoseAlertKeys, key.key) {
+			value = settings.FromUnits(value, units)
+		}
+		*key.value(&alert) = value
+		database.Set(prefix+key.key, value)
+	}
+
+	return alert
+}
+
+// loadSchedule loads the schedule which switches between profiles.
+func loadSchedule() []settings.Schedule {
+	value := database.Get("ALERT_SCHEDULE")
+	if value == "" {
+		return []settings.Schedule{
+			{Days: "daily", Time: "07:00", Profile: "day"},
+			{Days: "daily", Time: "22:00", Profile: "night"},
+		}
+	}
+	var schedule []settings.Schedule
+	err := json.Unmarshal([]byte(value), &schedule)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+	}
+
+	return schedule
+}
+
+// saveSchedule saves the schedule posted from the settings form, rows without a time are removed.
+func saveSchedule(req *http.Request) []settings.Schedule {
+	schedule := []settings.Schedule{}
+	days := req.PostForm["schedule_days"]
+	times := req.PostForm["schedule_time"]
+	profiles := req.PostForm["schedule_profile"]
+	for i := range times {
+		if times[i] == "" || i >= len(days) || i >= len(profiles) {
+			continue
+		}
+		schedule = append(schedule, settings.Schedule{Days: days[i], Time: times[i], Profile: profiles[i]})
+	}
+	value, err := json.Marshal(schedule)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		return schedule
+	}
+	database.Set("ALERT_SCHEDULE", string(value))
+
+	return schedule
+}
+
+// SetProfile switches the active alert profile.
+func SetProfile(name string) {
+	settingsMutex.Lock()
+	defer settingsMutex.Unlock()
+	alerts, ok := current.ProfileAlerts(name)
+	if !ok {
+		return
+	}
+	current.Profile = name
+	current.Alerts = alerts
+	database.Set("ALERT_PROFILE", name)
+}
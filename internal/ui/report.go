@@ -0,0 +1,40 @@
+package ui
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/directory"
+	"github.com/brettcodling/SugarMateReader/internal/notify"
+	"github.com/brettcodling/SugarMateReader/internal/report"
+)
+
+// handleReport serves the AGP report as an HTML page, or as a PNG image from /report.png.
+func handleReport(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	setting := Settings()
+	agp, err := report.Generate(setting, time.Now(), database.GetReadings)
+	var body []byte
+	if err == nil {
+		if req.URL.Path == "/report.png" {
+			w.Header().Set("Content-Type", "image/png")
+			body, err = report.PNG(agp, setting, directory.ConfigDir+"Roboto-Bold.ttf")
+		} else {
+			body, err = report.HTML(agp, setting, directory.ConfigDir+"Roboto-Bold.ttf")
+		}
+	}
+	if err != nil {
+		notify.Warning("ERROR!", err.Error())
+		log.Println("error:")
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Write(body)
+}
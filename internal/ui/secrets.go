@@ -0,0 +1,26 @@
+package ui
+
+import (
+	"log"
+
+	keyring "github.com/zalando/go-keyring"
+)
+
+// saveSecret stores a secret posted from the settings form in the keyring. Secrets aren't shown in
+// the form, so a blank field keeps the secret already stored for the user.
+func saveSecret(service, user, posted string) string {
+	if user == "" {
+		return posted
+	}
+	if posted == "" {
+		secret, _ := keyring.Get(service, user)
+		return secret
+	}
+	err := keyring.Set(service, user, posted)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+	}
+
+	return posted
+}
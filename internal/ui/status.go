@@ -0,0 +1,43 @@
+package ui
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/status"
+)
+
+// Status gathers the details of the latest reading served from /status, it's set once the alerts
+// are loaded.
+var Status func(now time.Time) (status.Details, error)
+
+// handleStatus describes the latest reading as plain text so status bars can show it while the
+// app is running, on a single line with the short query parameter.
+func handleStatus(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if Status == nil {
+		http.Error(w, "No reading", http.StatusServiceUnavailable)
+		return
+	}
+
+	now := time.Now()
+	details, err := Status(now)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	setting := Settings()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if req.URL.Query().Has("short") {
+		fmt.Fprintln(w, status.Short(details, setting, now))
+		return
+	}
+	fmt.Fprintln(w, status.Format(details, setting, now))
+}
@@ -0,0 +1,53 @@
+package ui
+
+import (
+	"slices"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+// thresholdKeys gets the database keys of every setting stored in mg/dl.
+func thresholdKeys() []string {
+	keys := []string{"LOW_RANGE", "HIGH_RANGE", "INSULIN_SENSITIVITY"}
+	for _, name := range settings.ProfileNames {
+		for _, key := range glucoseAlertKeys {
+			keys = append(keys, profileKeyPrefix(name)+key)
+		}
+	}
+
+	return keys
+}
+
+// migrateThresholds converts thresholds saved before they were stored in mg/dl, which were saved in
+// the units selected at the time.
+func migrateThresholds(units string) {
+	if database.Get("THRESHOLD_UNITS") == "mgdl" {
+		return
+	}
+	if units == "mmol" {
+		for _, key := range thresholdKeys() {
+			if value := database.Get(key); value != "" {
+				database.Set(key, settings.FromUnits(value, units))
+			}
+		}
+	}
+	database.Set("THRESHOLD_UNITS", "mgdl")
+}
+
+// thresholdsInUnits converts the thresholds of the settings into the selected units for the
+// settings form.
+func thresholdsInUnits(setting settings.Setting) settings.Setting {
+	setting.Range.Low = setting.ToUnits(setting.Range.Low)
+	setting.Range.High = setting.ToUnits(setting.Range.High)
+	setting.Insulin.Sensitivity = setting.ToUnits(setting.Insulin.Sensitivity)
+	setting.Profiles = slices.Clone(setting.Profiles)
+	for i, profile := range setting.Profiles {
+		profile.Alerts.Low = setting.ToUnits(profile.Alerts.Low)
+		profile.Alerts.High = setting.ToUnits(profile.Alerts.High)
+		profile.Alerts.FastChange = setting.ToUnits(profile.Alerts.FastChange)
+		setting.Profiles[i].Alerts = profile.Alerts
+	}
+
+	return setting
+}
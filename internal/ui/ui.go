@@ -6,19 +6,31 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
 
 	_ "embed"
 
+	"github.com/brettcodling/SugarMateReader/internal/alert"
 	"github.com/brettcodling/SugarMateReader/internal/auth"
 	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/metrics"
 	"github.com/brettcodling/SugarMateReader/internal/notify"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
 	"github.com/pkg/browser"
 	keyring "github.com/zalando/go-keyring"
 )
 
 var (
+	//go:embed alerts.tmpl
+	alertsTmpl string
 	//go:embed close.tmpl
 	closeTmpl string
+	//go:embed dashboard.tmpl
+	dashboardTmpl string
 	//go:embed layout.tmpl
 	layoutTmpl string
 	//go:embed login.tmpl
@@ -26,44 +38,45 @@ var (
 	//go:embed settings.tmpl
 	settingsTmpl string
 	RefreshCh    chan bool
-	Settings     Setting
-	url          string
+	// current is the settings in use, replaced or changed under settingsMutex and read through Settings
+	current       settings.Setting
+	settingsMutex sync.RWMutex
+	url           string
 )
 
-type Setting struct {
-	Alerts Alert
-	Format string
-	Range  Range
-	Saved  bool
-	Units  string
-}
-
-type Alert struct {
-	LowEnabled        string
-	Low               string
-	HighEnabled       string
-	High              string
-	FastChangeEnabled string
-	FastChange        string
-}
-
-type Range struct {
-	Low  string
-	High string
-}
-
-// init initialises the auth environment variables.
+// init starts the ui server and loads the settings.
 func init() {
-	listener, err := net.Listen("tcp", ":0")
+	RefreshCh = make(chan bool)
+	// the server has no authentication so it only listens locally, UI_ADDRESS gives it a fixed
+	// address such as 127.0.0.1:9412 so /metrics can be scraped, or :9412 to listen on the network
+	address := os.Getenv("UI_ADDRESS")
+	if address == "" {
+		address = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", address)
 	if err != nil {
 		notify.Warning("ERROR!", err.Error())
 		log.Fatal(err)
 	}
+	http.HandleFunc("/", handleDashboard)
+	http.HandleFunc("/alerts", handleAlerts)
+	http.HandleFunc("/export", handleExport)
 	http.HandleFunc("/login", handleLogin)
+	http.HandleFunc("/metrics", metrics.Handler)
+	http.HandleFunc("/report", handleReport)
+	http.HandleFunc("/report.png", handleReport)
 	http.HandleFunc("/settings", handleSettings)
+	http.HandleFunc("/status", handleStatus)
+	http.HandleFunc("/treatments", handleTreatments)
 	go http.Serve(listener, nil)
 	url = fmt.Sprintf("http://localhost:%d", listener.Addr().(*net.TCPAddr).Port)
 
+	loadSettings()
+}
+
+// Login makes sure there are credentials to authenticate with, opening the login window and
+// waiting for the user to log in when there aren't.
+func Login() {
 	if auth.Email != "" {
 		err := auth.LoadPassword()
 		if err != nil {
@@ -78,10 +91,6 @@ func init() {
 		OpenLogin()
 		<-RefreshCh
 	}
-
-	loadSettings()
-
-	RefreshCh = make(chan bool)
 }
 
 func handleLogin(w http.ResponseWriter, req *http.Request) {
@@ -139,116 +148,119 @@ func handleSettings(w http.ResponseWriter, req *http.Request) {
 	saved := false
 	if req.Method == http.MethodPost {
 		req.ParseForm()
-		Settings.Range.Low = req.PostForm["range_low"][0]
-		database.Set("LOW_RANGE", req.PostForm["range_low"][0])
-		Settings.Range.High = req.PostForm["range_high"][0]
-		database.Set("HIGH_RANGE", req.PostForm["range_high"][0])
-		_, alertLowEnabled := req.PostForm["alert_low_enabled"]
-		if alertLowEnabled {
-			Settings.Alerts.LowEnabled = "true"
-			database.Set("LOW_ALERT_ENABLED", "true")
-			Settings.Alerts.Low = req.PostForm["alert_low"][0]
-			database.Set("LOW_ALERT", req.PostForm["alert_low"][0])
-		} else {
-			Settings.Alerts.LowEnabled = "false"
-			database.Set("LOW_ALERT_ENABLED", "false")
-			Settings.Alerts.Low = ""
-			database.Set("LOW_ALERT", "")
-		}
-		_, alertHighEnabled := req.PostForm["alert_high_enabled"]
-		if alertHighEnabled {
-			Settings.Alerts.HighEnabled = "true"
-			database.Set("HIGH_ALERT_ENABLED", "true")
-			Settings.Alerts.High = req.PostForm["alert_high"][0]
-			database.Set("HIGH_ALERT", req.PostForm["alert_high"][0])
-		} else {
-			Settings.Alerts.HighEnabled = "false"
-			database.Set("HIGH_ALERT_ENABLED", "false")
-			Settings.Alerts.High = ""
-			database.Set("HIGH_ALERT", "")
-		}
-		_, fastChangeEnabled := req.PostForm["fast_change_enabled"]
-		if fastChangeEnabled {
-			Settings.Alerts.FastChangeEnabled = "true"
-			database.Set("FAST_CHANGE_ENABLED", "true")
-		} else {
-			Settings.Alerts.FastChangeEnabled = "false"
-			database.Set("FAST_CHANGE_ENABLED", "false")
+		settingsMutex.Lock()
+		// the profiles are changed in place so they're copied from any settings being read
+		current.Profiles = slices.Clone(current.Profiles)
+		// glucose levels are posted in the units chosen in the form and stored in mg/dl
+		units := req.PostForm["unit"][0]
+		current.Range.Low = settings.FromUnits(req.PostForm["range_low"][0], units)
+		database.Set("LOW_RANGE", current.Range.Low)
+		current.Range.High = settings.FromUnits(req.PostForm["range_high"][0], units)
+		database.Set("HIGH_RANGE", current.Range.High)
+		for i, profile := range current.Profiles {
+			current.Profiles[i].Alerts = saveAlert(req, profile.Name, units)
 		}
-		Settings.Alerts.FastChange = req.PostForm["fast_change"][0]
-		database.Set("FAST_CHANGE", req.PostForm["fast_change"][0])
-		Settings.Units = req.PostForm["unit"][0]
+		current.Schedule = saveSchedule(req)
+		current.Webhooks = saveWebhooks(req)
+		current.MQTT = saveMQTT(req)
+		current.Influx = saveInflux(req)
+		current.Insulin = saveInsulin(req, units)
+		current.Poll = savePoll(req)
+		current.Email = saveEmail(req)
+		current.Alerts, _ = current.ProfileAlerts(current.Profile)
+		current.Rate.Window = req.PostForm["rate_window"][0]
+		database.Set("RATE_WINDOW", req.PostForm["rate_window"][0])
+		current.Rate.Per = req.PostForm["rate_per"][0]
+		database.Set("RATE_PER", req.PostForm["rate_per"][0])
+		current.Units = req.PostForm["unit"][0]
 		database.Set("UNIT", req.PostForm["unit"][0])
-		if req.PostForm["unit"][0] == "mmol" {
-			Settings.Format = "%.1f"
-		} else {
-			Settings.Format = "%.0f"
-		}
+		current.Display = saveDisplay(req)
+		current.Format = settings.ValueFormat(current.Units, current.Display.Decimals)
+		settingsMutex.Unlock()
 		saved = true
 		go func() {
 			RefreshCh <- true
 		}()
 	}
-	settings := Settings
-	settings.Saved = saved
-	t, err := template.New("settings").Parse(settingsTmpl + layoutTmpl)
+	setting := thresholdsInUnits(Settings())
+	setting.Saved = saved
+	// blank rows allow new entries to be added to the schedule
+	setting.Schedule = append(slices.Clone(setting.Schedule), settings.Schedule{}, settings.Schedule{})
+	setting.Webhooks = append(slices.Clone(setting.Webhooks), settings.Webhook{})
+	t, err := template.New("settings").Funcs(template.FuncMap{
+		"scheduleDays": func() []string {
+			return settings.ScheduleDayNames
+		},
+		"alertRules": func() []alert.Rule {
+			return alert.Rules
+		},
+		"has": slices.Contains[[]string],
+		"mgdlPerMmol": func() float64 {
+			return glucose.MgDlPerMmol
+		},
+	}).Parse(settingsTmpl + layoutTmpl)
 	if err != nil {
 		notify.Warning("ERROR!", err.Error())
 		log.Println("error:")
 		log.Println(err)
 		return
 	}
-	t.Execute(w, settings)
+	t.Execute(w, setting)
 }
 
 func loadSettings() {
-	Settings.Units = database.Get("UNIT")
-	if Settings.Units == "" {
-		Settings.Units = "mmol"
+	setting := settings.Setting{}
+	setting.Units = database.Get("UNIT")
+	if setting.Units == "" {
+		setting.Units = "mmol"
 	}
-	if Settings.Units == "mgdl" {
-		Settings.Format = "%.0f"
-	} else {
-		Settings.Format = "%.1f"
+	setting.Display = loadDisplay()
+	setting.Format = settings.ValueFormat(setting.Units, setting.Display.Decimals)
+	setting.Rate.Window = database.Get("RATE_WINDOW")
+	if setting.Rate.Window == "" {
+		setting.Rate.Window = "15"
 	}
-	Settings.Alerts.LowEnabled = database.Get("LOW_ALERT_ENABLED")
-	Settings.Alerts.HighEnabled = database.Get("HIGH_ALERT_ENABLED")
-	Settings.Alerts.FastChangeEnabled = database.Get("FAST_CHANGE_ENABLED")
-	Settings.Alerts.Low = database.Get("LOW_ALERT")
-	if Settings.Alerts.Low == "" {
-		Settings.Alerts.Low = "4.0"
-		if Settings.Units == "mgdl" {
-			Settings.Alerts.Low = "72"
-		}
+	setting.Rate.Per = database.Get("RATE_PER")
+	if setting.Rate.Per == "" {
+		setting.Rate.Per = "5"
 	}
-	Settings.Alerts.High = database.Get("HIGH_ALERT")
-	if Settings.Alerts.High == "" {
-		Settings.Alerts.High = "12.0"
-		if Settings.Units == "mgdl" {
-			Settings.Alerts.High = "216"
-		}
+	migrateThresholds(setting.Units)
+	setting.Range.Low = database.Get("LOW_RANGE")
+	if setting.Range.Low == "" {
+		setting.Range.Low = "81"
 	}
-	Settings.Range.Low = database.Get("LOW_RANGE")
-	if Settings.Range.Low == "" {
-		Settings.Range.Low = "4.5"
-		if Settings.Units == "mgdl" {
-			Settings.Range.Low = "81"
-		}
+	setting.Range.High = database.Get("HIGH_RANGE")
+	if setting.Range.High == "" {
+		setting.Range.High = "180"
 	}
-	Settings.Range.High = database.Get("HIGH_RANGE")
-	if Settings.Range.High == "" {
-		Settings.Range.High = "10.0"
-		if Settings.Units == "mgdl" {
-			Settings.Range.High = "180"
-		}
+	setting.Profiles = []settings.Profile{}
+	for _, name := range settings.ProfileNames {
+		setting.Profiles = append(setting.Profiles, settings.Profile{Name: name, Alerts: loadAlert(name)})
 	}
-	Settings.Alerts.FastChange = database.Get("FAST_CHANGE")
-	if Settings.Alerts.FastChange == "" {
-		Settings.Alerts.FastChange = "0.5"
-		if Settings.Units == "mgdl" {
-			Settings.Alerts.FastChange = "9"
-		}
+	setting.Schedule = loadSchedule()
+	setting.Webhooks = loadWebhooks()
+	setting.MQTT = loadMQTT()
+	setting.Influx = loadInflux()
+	setting.Insulin = loadInsulin()
+	setting.Poll = loadPoll()
+	setting.Email = loadEmail()
+	profile, ok := settings.ScheduledProfile(setting.Schedule, time.Now())
+	if !ok {
+		profile = database.Get("ALERT_PROFILE")
 	}
+	setting.Profile = "day"
+	settingsMutex.Lock()
+	current = setting
+	settingsMutex.Unlock()
+	SetProfile(profile)
+}
+
+// Settings gets a copy of the settings in use, which isn't changed when they're saved.
+func Settings() settings.Setting {
+	settingsMutex.RLock()
+	defer settingsMutex.RUnlock()
+
+	return current
 }
 
 // OpenLogin will open the login window
@@ -256,6 +268,21 @@ func OpenLogin() {
 	browser.OpenURL(url + "/login")
 }
 
+// OpenDashboard will open the dashboard window
+func OpenDashboard() {
+	browser.OpenURL(url)
+}
+
+// OpenAlerts will open the alert history window
+func OpenAlerts() {
+	browser.OpenURL(url + "/alerts")
+}
+
+// OpenReport will open the AGP report window
+func OpenReport() {
+	browser.OpenURL(url + "/report")
+}
+
 // OpenSettings will open the settings window
 func OpenSettings() {
 	browser.OpenURL(url + "/settings")
@@ -0,0 +1,79 @@
+package ui
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/brettcodling/SugarMateReader/internal/database"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+// loadWebhooks loads the webhooks alerts and readings are posted to.
+func loadWebhooks() []settings.Webhook {
+	value := database.Get("WEBHOOKS")
+	if value == "" {
+		return []settings.Webhook{}
+	}
+	var webhooks []settings.Webhook
+	err := json.Unmarshal([]byte(value), &webhooks)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+	}
+
+	return webhooks
+}
+
+// saveWebhooks saves the webhooks posted from the settings form, rows without a URL are removed.
+// Secrets aren't shown in the form, so a blank secret keeps the one saved for the URL unless it's
+// cleared.
+func saveWebhooks(req *http.Request) []settings.Webhook {
+	webhooks := []settings.Webhook{}
+	for i := 0; req.PostForm.Has(webhookField(i, "url")); i++ {
+		address := req.PostForm.Get(webhookField(i, "url"))
+		if address == "" {
+			continue
+		}
+		readings := "false"
+		if req.PostForm.Has(webhookField(i, "readings")) {
+			readings = "true"
+		}
+		secret := req.PostForm.Get(webhookField(i, "secret"))
+		if secret == "" && !req.PostForm.Has(webhookField(i, "clear_secret")) {
+			secret = savedWebhookSecret(address)
+		}
+		webhooks = append(webhooks, settings.Webhook{
+			URL:      address,
+			Secret:   secret,
+			Alerts:   req.PostForm[webhookField(i, "alerts")],
+			Readings: readings,
+		})
+	}
+	value, err := json.Marshal(webhooks)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		return webhooks
+	}
+	database.Set("WEBHOOKS", string(value))
+
+	return webhooks
+}
+
+// savedWebhookSecret gets the secret saved for the webhook URL, settingsMutex must be held.
+func savedWebhookSecret(address string) string {
+	for _, webhook := range current.Webhooks {
+		if webhook.URL == address {
+			return webhook.Secret
+		}
+	}
+
+	return ""
+}
+
+// webhookField gets the name of a field of the webhook at the index in the settings form.
+func webhookField(i int, field string) string {
+	return "webhook_" + strconv.Itoa(i) + "_" + field
+}
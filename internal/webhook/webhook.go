@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/payload"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+// SignatureHeader holds the hex encoded HMAC-SHA256 of the body, signed with the webhook secret.
+const SignatureHeader = "X-SugarMateReader-Signature"
+
+var (
+	client     = &http.Client{Timeout: 10 * time.Second}
+	attempts   = 3
+	retryDelay = 2 * time.Second
+)
+
+// Send posts the payload to the webhook, retrying on network errors and 5xx responses. Other
+// responses are returned straight away as sending the same request again won't change them.
+func Send(hook settings.Webhook, content payload.Payload) error {
+	body, err := json.Marshal(content)
+	if err != nil {
+		return err
+	}
+	for attempt := 1; ; attempt++ {
+		retry, err := post(hook, body)
+		if err == nil || !retry || attempt >= attempts {
+			return err
+		}
+		time.Sleep(retryDelay * time.Duration(attempt))
+	}
+}
+
+// post makes a single attempt to post the body to the webhook, retry is whether the error could
+// be temporary.
+func post(hook settings.Webhook, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hook.Secret != "" {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(hook.Secret, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode >= 500, fmt.Errorf("webhook %s responded with %s", hook.URL, resp.Status)
+	}
+
+	return false, nil
+}
+
+// Sign gets the hex encoded HMAC-SHA256 of the body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Listen posts every alert event to the webhooks which have selected its rule.
+func Listen(events <-chan alert.Event, setting func() settings.Setting) {
+	for event := range events {
+		current := setting()
+		for _, hook := range current.Webhooks {
+			if slices.Contains(hook.Alerts, event.Rule) {
+				go send(hook, payload.Alert(event, current))
+			}
+		}
+	}
+}
+
+// PublishReading posts the reading to the webhooks which want every reading.
+func PublishReading(reading glucose.Reading, setting settings.Setting) {
+	for _, hook := range setting.Webhooks {
+		if hook.Readings == "true" {
+			go send(hook, payload.Reading(reading, setting))
+		}
+	}
+}
+
+func send(hook settings.Webhook, content payload.Payload) {
+	err := Send(hook, content)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+	}
+}
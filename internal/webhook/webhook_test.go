@@ -0,0 +1,140 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/payload"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+)
+
+func init() {
+	retryDelay = time.Millisecond
+}
+
+func TestSendSignsPayload(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ = io.ReadAll(req.Body)
+		signature = req.Header.Get(SignatureHeader)
+	}))
+	defer server.Close()
+
+	event := alert.Event{
+		Rule:      "low",
+		Message:   "Low: 3.5",
+		Threshold: "4.0",
+		Reading: glucose.Reading{
+			Time:  time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			MgDl:  63,
+			Trend: "FALLING",
+		},
+	}
+	hook := settings.Webhook{URL: server.URL, Secret: "secret"}
+	err := Send(hook, payload.Alert(event, settings.Setting{Units: "mmol"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if signature != "sha256="+Sign("secret", body) {
+		t.Errorf("signature = %q, want signature of %s", signature, body)
+	}
+	var posted payload.Payload
+	err = json.Unmarshal(body, &posted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posted.Type != "alert" || posted.Rule != "low" || posted.Value != 3.5 || posted.Unit != "mmol/L" ||
+		posted.Trend != "FALLING" || posted.Timestamp != "2024-01-02T03:04:05Z" {
+		t.Errorf("payload = %+v", posted)
+	}
+	if posted.Threshold == nil || *posted.Threshold != 4 {
+		t.Errorf("threshold = %v, want 4", posted.Threshold)
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	reading := glucose.Reading{Time: time.Now(), MgDl: 100}
+	err := Send(settings.Webhook{URL: server.URL}, payload.Reading(reading, settings.Setting{Units: "mgdl"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3", calls.Load())
+	}
+}
+
+func TestSendGivesUp(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	err := Send(settings.Webhook{URL: server.URL}, payload.Payload{Type: "reading"})
+	if err == nil {
+		t.Error("expected an error")
+	}
+	if calls.Load() != int32(attempts) {
+		t.Errorf("calls = %d, want %d", calls.Load(), attempts)
+	}
+}
+
+func TestSendClientErrorNotRetried(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	err := Send(settings.Webhook{URL: server.URL}, payload.Payload{Type: "reading"})
+	if err == nil {
+		t.Error("expected an error")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestReadingPayloadWithoutSecret(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received <- req
+	}))
+	defer server.Close()
+
+	PublishReading(glucose.Reading{Time: time.Now(), MgDl: 100}, settings.Setting{
+		Units: "mgdl",
+		Webhooks: []settings.Webhook{
+			{URL: server.URL, Readings: "true"},
+			{URL: "http://127.0.0.1:0", Readings: "false"},
+		},
+	})
+
+	select {
+	case req := <-received:
+		if req.Header.Get(SignatureHeader) != "" {
+			t.Error("unsigned webhook has a signature")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("reading was not posted")
+	}
+}
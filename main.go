@@ -6,14 +6,31 @@ import (
 	"log"
 	"log/syslog"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/brettcodling/SugarMateReader/internal/alert"
+	"github.com/brettcodling/SugarMateReader/internal/auth"
 	"github.com/brettcodling/SugarMateReader/internal/database"
 	"github.com/brettcodling/SugarMateReader/internal/directory"
+	"github.com/brettcodling/SugarMateReader/internal/email"
+	"github.com/brettcodling/SugarMateReader/internal/glucose"
+	"github.com/brettcodling/SugarMateReader/internal/img"
+	"github.com/brettcodling/SugarMateReader/internal/influx"
+	"github.com/brettcodling/SugarMateReader/internal/metrics"
+	"github.com/brettcodling/SugarMateReader/internal/mqtt"
 	"github.com/brettcodling/SugarMateReader/internal/notify"
+	"github.com/brettcodling/SugarMateReader/internal/poll"
+	"github.com/brettcodling/SugarMateReader/internal/power"
 	"github.com/brettcodling/SugarMateReader/internal/readings"
+	"github.com/brettcodling/SugarMateReader/internal/settings"
+	"github.com/brettcodling/SugarMateReader/internal/stats"
+	"github.com/brettcodling/SugarMateReader/internal/status"
+	"github.com/brettcodling/SugarMateReader/internal/treatment"
 	"github.com/brettcodling/SugarMateReader/internal/ui"
+	"github.com/brettcodling/SugarMateReader/internal/webhook"
 	"github.com/getlantern/systray"
 	"github.com/go-co-op/gocron"
 	"github.com/pkg/browser"
@@ -22,7 +39,21 @@ import (
 var (
 	//go:embed assets/*
 	assets             embed.FS
+	alerts             = alert.NewEngine(alert.Rules...)
+	lastReadingTime    time.Time
 	lastUpdateMenuItem *systray.MenuItem
+	profileMenuItems   = map[string]*systray.MenuItem{}
+	statsMenuItem      *systray.MenuItem
+	onBoardMenuItem    *systray.MenuItem
+	recentMenuItem     *systray.MenuItem
+	recentMenuItems    []*systray.MenuItem
+	periodMenuItems    = map[string]*systray.MenuItem{}
+	cachedStatsKey     statsKey
+	cachedPeriodStats  map[string]stats.Stats
+	scheduler          *gocron.Scheduler
+	// updateMutex is held while fetching readings, setting the icon and scheduling the next fetch, so
+	// the poll, the power events and saving the settings don't update at the same time
+	updateMutex sync.Mutex
 )
 
 func init() {
@@ -47,27 +78,65 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
 	defer database.DB.Close()
+	ui.Login()
+	err := alerts.Load([]byte(database.Get("ALERT_STATE")))
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+	}
+	alerts.Persist = func(data []byte) {
+		database.Set("ALERT_STATE", string(data))
+	}
+	alerts.Log = func(record alert.Record) {
+		err := database.SaveAlert(record)
+		if err != nil {
+			log.Println("error:")
+			log.Println(err)
+		}
+	}
+	go notify.Listen(alerts, alerts.Subscribe(), func() settings.Alert {
+		return ui.Settings().Alerts
+	})
+	go webhook.Listen(alerts.Subscribe(), func() settings.Setting {
+		return ui.Settings()
+	})
+	go email.Listen(alerts.Subscribe(), func() settings.Setting {
+		return ui.Settings()
+	}, database.GetReadings)
+	go metrics.Listen(alerts.Subscribe())
+	ui.Status = latestDetails
+	mqtt.Configure(ui.Settings())
+	go mqtt.Listen(alerts.Subscribe(), func() settings.Setting {
+		return ui.Settings()
+	})
+	tz, _ := time.LoadLocation("Local")
+	if tz == nil {
+		tz = time.UTC
+	}
+	scheduler = gocron.NewScheduler(tz)
 
 	systray.Run(func() {
 		setMenuItems()
+		updateMutex.Lock()
 		setIcon()
 		if readings.LastUpdateTime == "" {
 			notify.Warning("ERROR!", "Couldn't get any readings")
 			log.Fatal("No readings available")
 		}
-		tz, _ := time.LoadLocation("Local")
-		if tz == nil {
-			tz = time.UTC
-		}
-		s := gocron.NewScheduler(tz)
-		specificTime, err := time.Parse(time.RFC3339Nano, readings.LastUpdateTime)
+		_, err := time.Parse(time.RFC3339Nano, readings.LastUpdateTime)
 		if err != nil {
 			notify.Warning("ERROR!", "Failed to parse last reading time")
 			log.Fatal("Failed to parse last reading time")
 		}
-		s.Every(5).Minutes().StartAt(specificTime.Add(10 * time.Second)).Do(setIcon)
-		s.StartAsync()
+		schedulePoll()
+		updateMutex.Unlock()
+		scheduleProfiles()
+		scheduler.StartAsync()
+		go listenPower()
 	}, func() {})
 }
 
@@ -94,7 +163,75 @@ func setAutostart(autostart bool) error {
 	return nil
 }
 
-// setIcon sets the systray icon to the reading image.
+// fetchReading updates the reading then schedules the next fetch.
+func fetchReading() {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+	setIcon()
+	schedulePoll()
+}
+
+// schedulePoll schedules the next fetch for when the reading after the latest one is expected, so
+// the schedule follows the sensor when its readings drift or arrive late. updateMutex must be held.
+func schedulePoll() {
+	history, err := database.GetReadings(lastReadingTime.Add(-time.Hour), lastReadingTime)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+	}
+	setting := ui.Settings().Poll
+	next := poll.Next(
+		lastReadingTime,
+		time.Now(),
+		poll.Cadence(setting, history),
+		poll.Seconds(setting.Delay, poll.DefaultDelay),
+		poll.Seconds(setting.Retry, poll.DefaultRetry),
+	)
+	scheduler.RemoveByTag("poll")
+	_, err = scheduler.Every(1).Day().StartAt(next).LimitRunsTo(1).Tag("poll").Do(fetchReading)
+	if err != nil {
+		notify.Warning("ERROR!", err.Error())
+		log.Println("error:")
+		log.Println(err)
+	}
+}
+
+// listenPower refreshes straight away when the computer resumes from sleep or the network
+// connects. Timers don't run while asleep so the schedule is behind by then.
+func listenPower() {
+	events := make(chan power.Event, 1)
+	err := power.Listen(events)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		return
+	}
+	for event := range events {
+		log.Println("refreshing, " + string(event))
+		refresh()
+	}
+}
+
+// refresh catches up after readings were missed, fetching the missed readings, switching to the
+// profile which should be active and fetching the latest reading. An expired token is renewed by
+// the fetches.
+func refresh() {
+	updateMutex.Lock()
+	defer updateMutex.Unlock()
+	err := readings.Backfill(lastReadingTime, time.Now())
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+	}
+	scheduleProfiles()
+	if profile, ok := settings.ScheduledProfile(ui.Settings().Schedule, time.Now()); ok {
+		setProfile(profile)
+	}
+	setIcon()
+	schedulePoll()
+}
+
+// setIcon sets the systray icon to the reading image, updateMutex must be held.
 func setIcon() {
 	defer func() {
 		if err := recover(); err != nil {
@@ -103,8 +240,37 @@ func setIcon() {
 		}
 	}()
 	reading := readings.GetReading(true, "", "")
-	if len(reading) > 0 {
-		systray.SetIcon(reading)
+	setting := ui.Settings()
+	if !reading.Missing() {
+		history, err := database.GetReadings(reading.Time.Add(-time.Hour), reading.Time)
+		if err != nil {
+			log.Println("error:")
+			log.Println(err)
+		}
+		reading.Rate = readingRate(reading, history, setting)
+		metrics.SetReading(reading)
+		treatments, err := database.GetTreatments(reading.Time.Add(-treatment.Lookback), reading.Time)
+		if err != nil {
+			log.Println("error:")
+			log.Println(err)
+		}
+		alerts.Evaluate(alert.Input{Reading: reading, History: history, Treatments: treatments, Setting: setting})
+		// setIcon also runs when the settings are saved, only new readings are published
+		if reading.Time.After(lastReadingTime) {
+			lastReadingTime = reading.Time
+			webhook.PublishReading(reading, setting)
+			mqtt.PublishReading(reading, setting)
+			go influx.PublishReading(reading, setting, auth.Email, database.GetReadings)
+		}
+		icon, err := img.BuildImage(reading, setting, img.DefaultTheme())
+		if err != nil {
+			notify.Warning("ERROR!", err.Error())
+			log.Println("error:")
+			log.Println(err)
+			return
+		}
+		systray.SetIcon(icon)
+		systray.SetTooltip(status.Format(readingDetails(reading), setting, time.Now()))
 		lastUpdateTime, err := time.ParseInLocation(time.RFC3339Nano, readings.LastUpdateTime, time.UTC)
 		if err != nil {
 			log.Println(err)
@@ -112,12 +278,42 @@ func setIcon() {
 			return
 		}
 		lastUpdateMenuItem.SetTitle(fmt.Sprintf("Last updated: %s", lastUpdateTime.Local().Format(time.TimeOnly)))
+		updateRecentMenuItems()
+		updateStatsMenuItems()
+		onBoardMenuItem.SetTitle(ui.OnBoard(time.Now()))
 	}
 }
 
+// readingRate calculates the smoothed rate of the reading from the stored readings before it.
+func readingRate(reading glucose.Reading, history []glucose.Reading, setting settings.Setting) float64 {
+	// the reading is stored before it's returned so the history normally ends with it already,
+	// counting it twice would weight it double in the median
+	recent := history
+	if len(recent) == 0 || !recent[len(recent)-1].Time.Equal(reading.Time) {
+		recent = append(slices.Clone(history), reading)
+	}
+	rate, _ := glucose.Rate(recent, setting.Rate.WindowDuration())
+
+	return rate
+}
+
+// readingDetails gathers the details of a reading shown in the tooltip and by the status command.
+func readingDetails(reading glucose.Reading) status.Details {
+	details := status.Details{Reading: reading}
+	for _, rule := range alerts.Rules() {
+		details.Alerts = append(details.Alerts, status.Alert{Label: rule.Label, State: alerts.State(rule.Name)})
+	}
+
+	return details
+}
+
 func setMenuItems() {
 	lastUpdateMenuItem = systray.AddMenuItem("", "")
 	lastUpdateMenuItem.Disable()
+	onBoardMenuItem = systray.AddMenuItem("", "")
+	onBoardMenuItem.Disable()
+	setRecentMenuItems()
+	setStatsMenuItems()
 	goToUrl := systray.AddMenuItem("Open in browser", "")
 	login := systray.AddMenuItem("Login", "")
 	if _, err := os.Stat("/usr/local/bin/SugarMateReader"); err == nil {
@@ -146,6 +342,10 @@ func setMenuItems() {
 			}
 		}()
 	}
+	setAlertMenuItems()
+	setProfileMenuItems()
+	agpReport := systray.AddMenuItem("AGP report", "")
+	alertHistory := systray.AddMenuItem("Alert history", "")
 	settings := systray.AddMenuItem("Settings", "")
 	systray.AddSeparator()
 	quit := systray.AddMenuItem("Quit", "")
@@ -156,13 +356,206 @@ func setMenuItems() {
 				browser.OpenURL("https://app.sugarmate.io")
 			case <-login.ClickedCh:
 				go ui.OpenLogin()
+			case <-agpReport.ClickedCh:
+				go ui.OpenReport()
+			case <-alertHistory.ClickedCh:
+				go ui.OpenAlerts()
 			case <-settings.ClickedCh:
 				go ui.OpenSettings()
 			case <-quit.ClickedCh:
 				systray.Quit()
 			case <-ui.RefreshCh:
-				setIcon()
+				mqtt.Configure(ui.Settings())
+				scheduleProfiles()
+				fetchReading()
+			}
+		}
+	}()
+}
+
+// setAlertMenuItems adds the menu items used to acknowledge and snooze alerts.
+func setAlertMenuItems() {
+	acknowledge := systray.AddMenuItem("Acknowledge alerts", "")
+	go func() {
+		for range acknowledge.ClickedCh {
+			for _, rule := range alerts.Rules() {
+				alerts.Acknowledge(rule.Name)
 			}
 		}
 	}()
+	snooze := systray.AddMenuItem("Snooze alerts", "")
+	for _, rule := range alerts.Rules() {
+		ruleItem := snooze.AddSubMenuItem(rule.Label, "")
+		for _, duration := range alert.SnoozeDurations {
+			durationItem := ruleItem.AddSubMenuItem(fmt.Sprintf("%d min", int(duration.Minutes())), "")
+			go func(name string, duration time.Duration) {
+				for range durationItem.ClickedCh {
+					alerts.Snooze(name, duration)
+				}
+			}(rule.Name, duration)
+		}
+	}
+}
+
+// setProfileMenuItems adds the menu items used to switch alert profile.
+func setProfileMenuItems() {
+	profiles := systray.AddMenuItem("Alert profile", "")
+	setting := ui.Settings()
+	for _, profile := range setting.Profiles {
+		item := profiles.AddSubMenuItemCheckbox(strings.ToUpper(profile.Name[:1])+profile.Name[1:], "", profile.Name == setting.Profile)
+		profileMenuItems[profile.Name] = item
+		go func(name string) {
+			for range item.ClickedCh {
+				setProfile(name)
+			}
+		}(profile.Name)
+	}
+}
+
+// recentReadings is the number of readings listed in the recent readings submenu.
+const recentReadings = 12
+
+// setRecentMenuItems adds the recent readings submenu, its items are filled in as readings arrive.
+func setRecentMenuItems() {
+	recentMenuItem = systray.AddMenuItem("Recent readings", "")
+	for range recentReadings {
+		item := recentMenuItem.AddSubMenuItem("", "")
+		item.Disable()
+		item.Hide()
+		recentMenuItems = append(recentMenuItems, item)
+	}
+}
+
+// updateRecentMenuItems lists the latest stored readings, newest first.
+func updateRecentMenuItems() {
+	now := time.Now()
+	history, err := database.GetReadings(now.Add(-3*time.Hour), now)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		return
+	}
+	slices.Reverse(history)
+	setting := ui.Settings()
+	for i, item := range recentMenuItems {
+		if i >= len(history) {
+			item.Hide()
+			continue
+		}
+		reading := history[i]
+		item.SetTitle(fmt.Sprintf("%s  %s %s", reading.Time.Local().Format("15:04"), setting.Value(float64(reading.MgDl)), glucose.Arrow(reading.Trend)))
+		item.Show()
+	}
+}
+
+// setStatsMenuItems adds the menu items showing the time in range with a submenu of the
+// statistics periods.
+func setStatsMenuItems() {
+	statsMenuItem = systray.AddMenuItem("", "")
+	for _, period := range stats.Periods {
+		item := statsMenuItem.AddSubMenuItem("", "")
+		item.Disable()
+		periodMenuItems[period.Name] = item
+	}
+	dashboard := statsMenuItem.AddSubMenuItem("Open dashboard", "")
+	go func() {
+		for range dashboard.ClickedCh {
+			ui.OpenDashboard()
+		}
+	}()
+}
+
+// statsKey is what the cached statistics of the longer periods were calculated for, they're
+// recalculated when the day or the target range changes.
+type statsKey struct {
+	day time.Time
+	rng settings.Range
+}
+
+// updateStatsMenuItems updates the time in range and average shown by the statistics menu items.
+// Today and the last 24 hours are calculated from a day of readings each update, the longer periods
+// read months of readings so they're cached for the day, updateMutex must be held.
+func updateStatsMenuItems() {
+	now := time.Now()
+	setting := ui.Settings()
+	low, high, err := stats.Range(setting)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		return
+	}
+	key := statsKey{
+		day: time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()),
+		rng: setting.Range,
+	}
+	if key != cachedStatsKey || cachedPeriodStats == nil {
+		periods := map[string]stats.Stats{}
+		for _, period := range stats.Periods {
+			if period.Duration <= 24*time.Hour {
+				continue
+			}
+			periods[period.Name], err = stats.Since(now.Add(-period.Duration), setting, database.GetReadings)
+			if err != nil {
+				log.Println("error:")
+				log.Println(err)
+				return
+			}
+		}
+		cachedStatsKey, cachedPeriodStats = key, periods
+	}
+	day, err := database.GetReadings(now.Add(-24*time.Hour), now)
+	if err != nil {
+		log.Println("error:")
+		log.Println(err)
+		return
+	}
+	// the readings are in time order so today's are the ones from the first reading since midnight
+	midnight, _ := slices.BinarySearchFunc(day, key.day, func(reading glucose.Reading, t time.Time) int {
+		return reading.Time.Compare(t)
+	})
+	today := stats.Calculate(day[midnight:], low, high)
+	title := "Today: " + today.InRange()
+	if today.Readings > 0 {
+		title += ", average " + setting.Value(today.Mean)
+	}
+	statsMenuItem.SetTitle(title)
+	for _, period := range stats.Periods {
+		calculated, ok := cachedPeriodStats[period.Name]
+		if !ok {
+			calculated = stats.Calculate(day, low, high)
+		}
+		periodMenuItems[period.Name].SetTitle(fmt.Sprintf("%s: %s, GMI %.1f%%", period.Name, calculated.InRange(), calculated.GMI))
+	}
+}
+
+// setProfile switches the active alert profile.
+func setProfile(name string) {
+	ui.SetProfile(name)
+	active := ui.Settings().Profile
+	for profile, item := range profileMenuItems {
+		if profile == active {
+			item.Check()
+		} else {
+			item.Uncheck()
+		}
+	}
+}
+
+// scheduleProfiles schedules switching between alert profiles.
+func scheduleProfiles() {
+	scheduler.RemoveByTag("profile")
+	for _, entry := range ui.Settings().Schedule {
+		if len(entry.Weekdays()) == 0 {
+			continue
+		}
+		job := scheduler.Every(1).Week()
+		for _, weekday := range entry.Weekdays() {
+			job = job.Weekday(weekday)
+		}
+		_, err := job.At(entry.Time).Tag("profile").Do(setProfile, entry.Profile)
+		if err != nil {
+			log.Println("error:")
+			log.Println(err)
+		}
+	}
 }